@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jclc/waylander/common"
+	"golang.org/x/exp/maps"
+)
+
+// aliasEntry is one alias in the output of the alias command.
+type aliasEntry struct {
+	Alias    string `json:"alias"`
+	Identity string `json:"identity"`
+}
+
+// RunAlias lists, sets or deletes the nicknames of monitors.
+func RunAlias(args []string) error {
+	set := newFlagSet()
+	del := set.Bool("delete", false, "Delete the alias")
+	format := outputFlag(set, "")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
+	aliases, err := common.LoadAliases()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *del:
+		if len(args) != 1 {
+			return usagef("specify the alias to delete")
+		}
+		if _, ok := aliases[args[0]]; !ok {
+			return fmt.Errorf("alias '%s' does not exist", args[0])
+		}
+		delete(aliases, args[0])
+		return common.SaveAliases(aliases)
+	case len(args) == 0:
+		return listAliases(aliases, *format)
+	case len(args) != 2:
+		return usagef("specify the alias and the monitor")
+	}
+
+	name, monitor := args[0], args[1]
+	if !common.ValidAlias(name) {
+		return usagef("invalid alias '%s'", name)
+	}
+
+	// Connectors are resolved to the identity of the connected monitor
+	identity := monitor
+	if !strings.Contains(monitor, "/") {
+		err := withSession(func() error {
+			res, err := session.Resources()
+			if err != nil {
+				return fmt.Errorf("error getting monitor resources: %w", err)
+			}
+			phys, ok := res.Monitors[monitor]
+			if !ok {
+				return usagef("output '%s' is not connected, give the monitor as vendor/product/serial", monitor)
+			}
+			identity = phys.Identity()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	aliases[name] = identity
+	return common.SaveAliases(aliases)
+}
+
+func listAliases(aliases common.Aliases, format string) error {
+	names := maps.Keys(aliases)
+	slices.Sort(names)
+	entries := make([]aliasEntry, len(names))
+	for i, name := range names {
+		entries[i] = aliasEntry{Alias: name, Identity: aliases[name]}
+	}
+
+	if format == "" {
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\n", entry.Alias, entry.Identity)
+		}
+		return nil
+	}
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	return writeOutput(os.Stdout, format, entries, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ALIAS\tIDENTITY")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\n", entry.Alias, entry.Identity)
+		}
+	})
+}
+
+// loadAliases returns the aliases for output, warning if they can't be
+// loaded.
+func loadAliases() common.Aliases {
+	aliases, err := common.LoadAliases()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+	return aliases
+}
+
+// addAliases sets the alias property of the monitors that have an alias.
+func addAliases(res common.Resources, aliases common.Aliases) {
+	for connector, phys := range res.Monitors {
+		name := aliases.Name(phys)
+		if name == "" {
+			continue
+		}
+		phys.Properties = maps.Clone(phys.Properties)
+		if phys.Properties == nil {
+			phys.Properties = map[string]any{}
+		}
+		phys.Properties[common.PropertyAlias] = name
+		res.Monitors[connector] = phys
+	}
+}
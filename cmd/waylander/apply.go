@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jclc/waylander/common"
+	"github.com/jclc/waylander/pulse"
+	"golang.org/x/exp/maps"
+)
+
+// applyOptions are the options shared by all the ways of applying a profile.
+type applyOptions struct {
+	Verify      bool
+	Persist     bool
+	NoHooks     bool
+	HookTimeout time.Duration
+	// Force applies the layout even if it is already applied
+	Force bool
+	// Map renames connectors of saved profiles in addition to the connector
+	// map in the config
+	Map common.ConnectorMap
+}
+
+// applyFlags adds the apply options to the flag set.
+func applyFlags(set *flag.FlagSet) *applyOptions {
+	var opts applyOptions
+	set.BoolVar(&opts.Persist, "persist", false,
+		"Make profile persistent")
+	set.BoolVar(&opts.Verify, "verify", false,
+		"Ask for confirmation")
+	set.BoolVar(&opts.NoHooks, "no-hooks", false,
+		"Don't run apply hooks")
+	set.DurationVar(&opts.HookTimeout, "hook-timeout", common.DefaultHookTimeout,
+		"Time each hook may run")
+	set.BoolVar(&opts.Force, "force", false,
+		"Apply even if the layout is already applied")
+	return &opts
+}
+
+// mapFlag adds the flag for renaming the connectors of saved profiles.
+func mapFlag(set *flag.FlagSet, opts *applyOptions) {
+	set.Func("map", "Connectors to rename as FROM=TO,...", func(s string) error {
+		m, err := common.ParseConnectorMap(s)
+		if err != nil {
+			return err
+		}
+		if opts.Map == nil {
+			opts.Map = common.ConnectorMap{}
+		}
+		for from, to := range m {
+			opts.Map[from] = to
+		}
+		return nil
+	})
+}
+
+// applyProfile applies the profile through the session and runs the apply
+// hooks around it. The name is empty for layouts that aren't saved profiles.
+// The connectors of saved profiles are remapped first. A layout that is
+// already applied is skipped along with the hooks unless forced or made
+// persistent, as reapplying it makes some displays blank.
+func applyProfile(sess common.DesktopSession, name string, profile common.Profile, opts applyOptions) error {
+	if name != "" {
+		var err error
+		profile, err = remapProfile(sess, profile, opts.Map)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !opts.Force && !opts.Persist && profileApplied(sess, profile) {
+		fmt.Fprintln(os.Stderr, "Layout already applied, use -force to apply it again")
+		return errors.Join(applyDisplaySettings(sess, profile), applyAudio(profile))
+	}
+
+	warnProfile(sess, profile)
+
+	if opts.NoHooks {
+		if err := sess.Apply(profile, opts.Verify, opts.Persist); err != nil {
+			return fmt.Errorf("error applying profile: %w", err)
+		}
+		if err := applyDisplaySettings(sess, profile); err != nil {
+			return err
+		}
+		return applyAudio(profile)
+	}
+
+	timeout := opts.HookTimeout
+	if timeout <= 0 {
+		timeout = common.DefaultHookTimeout
+	}
+
+	previous, err := sess.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	hctx := common.HookContext{
+		Profile:  name,
+		Previous: common.State{Monitors: previous},
+		New:      common.State{Monitors: profile.Monitors},
+	}
+
+	err = common.RunHooks(common.HookPreApply, hctx, timeout, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if err := sess.Apply(profile, opts.Verify, opts.Persist); err != nil {
+		return fmt.Errorf("error applying profile: %w", err)
+	}
+
+	// The backlights and audio outputs may only appear once the monitors
+	// are enabled
+	settingsErr := applyDisplaySettings(sess, profile)
+	audioErr := applyAudio(profile)
+
+	// Post-apply hooks get the resulting layout. Their failures don't
+	// affect the result as the profile has already been applied.
+	if current, err := sess.ScreenStates(); err == nil {
+		hctx.New.Monitors = current
+	}
+	err = common.RunHooks(common.HookPostApply, hctx, timeout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+
+	return errors.Join(settingsErr, audioErr)
+}
+
+// remapProfile renames the connectors of a saved profile using the connector
+// map in the config, the extra mappings, the aliases and the connected
+// outputs.
+func remapProfile(sess common.DesktopSession, profile common.Profile, extra common.ConnectorMap) (common.Profile, error) {
+	m, err := common.LoadConnectorMap()
+	if err != nil {
+		return profile, err
+	}
+	for from, to := range extra {
+		m[from] = to
+	}
+	aliases, err := common.LoadAliases()
+	if err != nil {
+		return profile, err
+	}
+
+	res, err := sess.Resources()
+	if err != nil {
+		return profile, fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	return common.RemapConnectors(profile, m, aliases, res)
+}
+
+// profileApplied returns true if the current layout is the same as the
+// profile's. Errors are left for Apply to report.
+func profileApplied(sess common.DesktopSession, profile common.Profile) bool {
+	monitors, err := sess.ScreenStates()
+	if err != nil {
+		return false
+	}
+	layoutMode, err := sess.LayoutMode()
+	if err != nil {
+		return false
+	}
+	// Applying switches the layout mode if the session can change it
+	if profile.LayoutMode != "" && profile.LayoutMode != layoutMode {
+		caps, err := sess.Capabilities()
+		if err != nil || caps.ChangeLayoutMode {
+			return false
+		}
+	}
+	return common.ProfileApplied(profile, monitors, layoutMode)
+}
+
+// warnProfile prints warnings about parts of the profile that the session
+// will apply differently than requested.
+func warnProfile(sess common.DesktopSession, profile common.Profile) {
+	res, err := sess.Resources()
+	if err != nil {
+		// Apply reports the error
+		return
+	}
+
+	connectors := maps.Keys(res.Monitors)
+	slices.Sort(connectors)
+	for _, connector := range connectors {
+		if !profile.IsConfigured(connector) &&
+			!profile.IsDisabled(connector, res.Monitors[connector]) {
+			fmt.Fprintf(os.Stderr,
+				"Warning: %s is not configured by the profile and will be turned off\n",
+				connector)
+		}
+	}
+
+	for _, mon := range profile.Monitors {
+		connectors := mon.Connectors()
+		if len(connectors) == 0 {
+			continue
+		}
+		mode := mon.Outputs[connectors[0]]
+		info, ok := res.Monitors[connectors[0]].FindMode(mode)
+		if !ok || len(info.SupportedScales) == 0 {
+			continue
+		}
+		scale := common.Closest(info.SupportedScales, mon.Scale)
+		if math.Abs(scale-mon.Scale) > common.Epsilon {
+			fmt.Fprintf(os.Stderr,
+				"Warning: scale %g is not supported by %s in mode %s, using %g\n",
+				mon.Scale, strings.Join(connectors, ","), formatMode(mode), scale)
+		}
+	}
+}
+
+// applyDisplaySettings sets the brightness and night light of the profile.
+func applyDisplaySettings(sess common.DesktopSession, profile common.Profile) error {
+	if len(profile.Brightness) == 0 && profile.NightLight == nil {
+		return nil
+	}
+	settings, ok := sess.(common.DisplaySettings)
+	if !ok {
+		return fmt.Errorf("%w: brightness and night light",
+			common.ErrUnsupportedFeature)
+	}
+
+	connectors := maps.Keys(profile.Brightness)
+	slices.Sort(connectors)
+	for _, connector := range connectors {
+		err := settings.SetBrightness(connector, profile.Brightness[connector])
+		if err != nil {
+			return fmt.Errorf("error setting brightness: %w", err)
+		}
+	}
+	if profile.NightLight != nil {
+		if err := settings.SetNightLight(*profile.NightLight); err != nil {
+			return fmt.Errorf("error setting night light: %w", err)
+		}
+	}
+	return nil
+}
+
+// layoutProfile returns a profile of the monitors with the layout mode and
+// the disabled outputs of the session.
+func layoutProfile(sess common.DesktopSession, monitors []common.LogicalMonitor) (common.Profile, error) {
+	layoutMode, err := sess.LayoutMode()
+	if err != nil {
+		return common.Profile{}, fmt.Errorf("error getting layout mode: %w", err)
+	}
+	res, err := sess.Resources()
+	if err != nil {
+		return common.Profile{}, fmt.Errorf("error getting monitor resources: %w", err)
+	}
+
+	return common.Profile{
+		Monitors:   monitors,
+		LayoutMode: layoutMode,
+		Disabled:   common.DisabledOutputs(res, monitors),
+	}, nil
+}
+
+// captureDisplaySettings adds the current brightness and night light to the
+// profile if the session supports them.
+func captureDisplaySettings(sess common.DesktopSession, profile *common.Profile) error {
+	settings, ok := sess.(common.DisplaySettings)
+	if !ok {
+		return nil
+	}
+
+	brightness, err := settings.Brightness()
+	if err != nil {
+		return fmt.Errorf("error getting brightness: %w", err)
+	}
+	if len(brightness) > 0 {
+		profile.Brightness = brightness
+	}
+
+	nl, err := settings.NightLight()
+	if err != nil {
+		return fmt.Errorf("error getting night light: %w", err)
+	}
+	profile.NightLight = &nl
+	return nil
+}
+
+// applyAudio switches the audio outputs of the profile, if it has any.
+func applyAudio(profile common.Profile) error {
+	if profile.Audio == nil {
+		return nil
+	}
+	if err := pulse.Apply(*profile.Audio); err != nil {
+		return fmt.Errorf("error applying audio: %w", err)
+	}
+	return nil
+}
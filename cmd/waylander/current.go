@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jclc/waylander/common"
+)
+
+// customLayout is printed when no saved profile matches the current layout.
+const customLayout = "custom"
+
+// currentResult is the output of the current command.
+type currentResult struct {
+	// Profiles are the saved profiles matching the current layout
+	Profiles []string `json:"profiles"`
+	// Nearest is the profile with the fewest differences if -diff was given
+	Nearest     string              `json:"nearest,omitempty"`
+	Differences []common.Difference `json:"differences,omitempty"`
+}
+
+// RunCurrent prints the saved profiles matching the current layout.
+func RunCurrent(args []string) error {
+	set := newFlagSet()
+	diff := set.Bool("diff", false, "Show the differences to the nearest profile")
+	format := outputFlag(set, "")
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if *format != "" {
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	monitors, err := session.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+	names, err := common.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	result := currentResult{Profiles: []string{}}
+	for _, name := range names {
+		profile, err := common.LoadProfile(name)
+		if err != nil {
+			var perr *common.ProfileError
+			if errors.As(err, &perr) {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s\n", err)
+				continue
+			}
+			return err
+		}
+		// Compare the outputs the profile would be applied to
+		profile, err = remapProfile(session, profile, nil)
+		if errors.Is(err, common.ErrInvalidProfile) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %s\n", name, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if common.LayoutMatches(profile, monitors, layoutMode) {
+			result.Profiles = append(result.Profiles, name)
+		}
+		if !*diff {
+			continue
+		}
+
+		want := common.CloneMonitors(profile.Monitors)
+		common.ConvertLayoutMode(want, profile.LayoutMode, layoutMode)
+		diffs := common.DiffCurrent(want, monitors)
+		if result.Nearest == "" || len(diffs) < len(result.Differences) {
+			result.Nearest = name
+			result.Differences = diffs
+		}
+	}
+
+	if *format != "" {
+		return writeOutput(os.Stdout, *format, &result, func(w *tabwriter.Writer) {
+			writeCurrentTable(w, result)
+		})
+	}
+
+	if len(result.Profiles) == 0 {
+		fmt.Println(customLayout)
+	}
+	for _, name := range result.Profiles {
+		fmt.Println(name)
+	}
+	if len(result.Differences) > 0 {
+		fmt.Printf("\nDifferences to profile '%s':\n", result.Nearest)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		writeDiffTable(w, result.Differences, "profile", "current")
+		return w.Flush()
+	}
+	return nil
+}
+
+// writeCurrentTable writes the matching profiles and the differences to the
+// nearest profile.
+func writeCurrentTable(w *tabwriter.Writer, result currentResult) {
+	fmt.Fprintln(w, "PROFILE")
+	if len(result.Profiles) == 0 {
+		fmt.Fprintln(w, customLayout)
+	}
+	for _, name := range result.Profiles {
+		fmt.Fprintln(w, name)
+	}
+	if len(result.Differences) > 0 {
+		fmt.Fprintln(w)
+		writeDiffTable(w, result.Differences, result.Nearest, "current")
+	}
+}
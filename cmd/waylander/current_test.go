@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+// captureStdout returns what the function writes to stdout.
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	err = fn()
+	w.Close()
+	data := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCurrentRemapsProfiles(t *testing.T) {
+	tempConfigDir(t)
+	stub := newStubSession()
+	stub.monitors = []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"eDP-1": stubPanelMode}, Scale: 1, Primary: true},
+		{Outputs: map[string]common.Mode{"HDMI-1": stubTVMode}, Scale: 2, Offset: common.Rect{X: 1920}},
+	}
+	orig := session
+	session = stub
+	t.Cleanup(func() { session = orig })
+
+	// The TV is named by its alias and the panel with the Xorg name
+	if err := common.SaveAliases(common.Aliases{"tv": "GSM/LG TV/2"}); err != nil {
+		t.Fatal(err)
+	}
+	desk := common.Profile{Monitors: []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"eDP1": stubPanelMode}, Scale: 1, Primary: true},
+		{Outputs: map[string]common.Mode{"tv": stubTVMode}, Scale: 2, Offset: common.Rect{X: 1920}},
+	}}
+	if err := common.SaveProfile("desk", desk); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() error { return RunCurrent([]string{"-diff", "-o", "json"}) })
+	var result currentResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	if !slices.Equal(result.Profiles, []string{"desk"}) || len(result.Differences) != 0 {
+		t.Errorf("got %+v", result)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jclc/waylander/common"
+)
+
+// RunCycle applies the profile after the one matching the current layout.
+// Profiles that don't fit the connected outputs are skipped.
+func RunCycle(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	mapFlag(set, opts)
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return usagef("specify the profiles to cycle through")
+	}
+
+	profiles := make([]common.Profile, len(args))
+	for i, name := range args {
+		profiles[i], err = common.LoadProfile(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	monitors, err := session.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+	res, err := session.Resources()
+	if err != nil {
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+
+	// The profiles are remapped for the checks, applyProfile remaps them
+	// again when applying
+	mapped := make([]common.Profile, len(profiles))
+	for i, profile := range profiles {
+		mapped[i], err = remapProfile(session, profile, opts.Map)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start from the first profile if none matches
+	current := -1
+	for i, profile := range mapped {
+		if common.LayoutMatches(profile, monitors, layoutMode) {
+			current = i
+			break
+		}
+	}
+
+	for n := 1; n <= len(profiles); n++ {
+		i := (current + n) % len(profiles)
+		if i == current {
+			break
+		}
+		if err := common.CheckCompatible(mapped[i], res); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping profile '%s': %s\n", args[i], err)
+			continue
+		}
+
+		if err := applyProfile(session, args[i], profiles[i], *opts); err != nil {
+			return err
+		}
+		fmt.Println(args[i])
+		return nil
+	}
+
+	// Staying on the current profile isn't an error
+	if current >= 0 {
+		fmt.Println(args[current])
+		return nil
+	}
+	return fmt.Errorf("%w: no profile fits the connected outputs",
+		common.ErrNoMatchingMode)
+}
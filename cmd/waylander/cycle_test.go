@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+func TestCycle(t *testing.T) {
+	tempConfigDir(t)
+	stub := newStubSession()
+	orig := session
+	session = stub
+	t.Cleanup(func() { session = orig })
+
+	panel := common.Profile{Monitors: []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"eDP-1": stubPanelMode}, Scale: 1, Primary: true},
+	}}
+	tv := common.Profile{Monitors: []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"DP-3": stubTVMode}, Scale: 2, Primary: true},
+	}}
+	for name, profile := range map[string]common.Profile{"panel": panel, "tv": tv} {
+		if err := common.SaveProfile(name, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The TV isn't connected to DP-3, so the current profile is kept
+	out := captureStdout(t, func() error { return RunCycle([]string{"panel", "tv"}) })
+	if strings.TrimSpace(out) != "panel" || len(stub.appliedProfiles()) != 0 {
+		t.Errorf("got output %q, applied %+v", out, stub.appliedProfiles())
+	}
+
+	out = captureStdout(t, func() error { return RunCycle([]string{"-map", "DP-3=HDMI-1", "panel", "tv"}) })
+	applied := stub.appliedProfiles()
+	if strings.TrimSpace(out) != "tv" || len(applied) != 1 {
+		t.Fatalf("got output %q, applied %+v", out, applied)
+	}
+	if _, ok := applied[0].Monitors[0].Outputs["HDMI-1"]; !ok {
+		t.Errorf("got applied profile %+v", applied[0])
+	}
+}
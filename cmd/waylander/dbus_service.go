@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/jclc/waylander/common"
+)
+
+const (
+	serviceName      = "io.github.jclc.Waylander"
+	serviceInterface = "io.github.jclc.Waylander"
+	servicePath      = "/io/github/jclc/Waylander"
+	serviceErrorName = "io.github.jclc.Waylander.Error"
+)
+
+// Flags of the ApplyProfile method
+const (
+	ApplyFlagPersist uint32 = 1 << iota
+	ApplyFlagVerify
+)
+
+func RunDBusService(args []string) error {
+	if _, err := parseFlags(newFlagSet(), args); err != nil {
+		return err
+	}
+
+	// Use a private connection as the desktop session may share the
+	// session bus connection
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to d-bus: %w", err)
+	}
+	defer conn.Close()
+
+	svc := &dbusService{
+		conn:    conn,
+		session: session,
+	}
+	if err := svc.export(); err != nil {
+		return err
+	}
+
+	reply, err := conn.RequestName(serviceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("failed to request name %s: %w", serviceName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("name %s is already taken", serviceName)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	return nil
+}
+
+// dbusService implements the io.github.jclc.Waylander interface. All of its
+// exported methods are exported on the bus.
+type dbusService struct {
+	conn    *dbus.Conn
+	session common.DesktopSession
+	sessionLocker
+}
+
+func (s *dbusService) export() error {
+	err := s.conn.Export(s, servicePath, serviceInterface)
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %w", serviceInterface, err)
+	}
+
+	node := &introspect.Node{
+		Name: servicePath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    serviceInterface,
+				Methods: introspect.Methods(s),
+				Signals: []introspect.Signal{{
+					Name: "ProfileApplied",
+					Args: []introspect.Arg{{Name: "name", Type: "s"}},
+				}},
+			},
+		},
+	}
+	err = s.conn.Export(introspect.NewIntrospectable(node), servicePath,
+		"org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return fmt.Errorf("failed to export introspection data: %w", err)
+	}
+	return nil
+}
+
+// ListProfiles returns the names of the saved profiles.
+func (s *dbusService) ListProfiles() ([]string, *dbus.Error) {
+	profiles, err := common.ListProfiles()
+	if err != nil {
+		return nil, dbusError(err)
+	}
+	if profiles == nil {
+		profiles = []string{}
+	}
+	return profiles, nil
+}
+
+// GetState returns the current layout as JSON in the same format as the
+// state command.
+func (s *dbusService) GetState() (string, *dbus.Error) {
+	var monitors []common.LogicalMonitor
+	err := s.locked(func() (err error) {
+		monitors, err = s.session.ScreenStates()
+		return err
+	})
+	if err != nil {
+		return "", dbusError(err)
+	}
+
+	data, err := json.Marshal(common.State{Monitors: monitors})
+	if err != nil {
+		return "", dbusError(err)
+	}
+	return string(data), nil
+}
+
+// ApplyProfile applies the named profile and emits ProfileApplied.
+func (s *dbusService) ApplyProfile(name string, flags uint32) *dbus.Error {
+	profile, err := common.LoadProfile(name)
+	if err != nil {
+		return dbusError(err)
+	}
+
+	err = s.locked(func() error {
+		return applyProfile(s.session, name, profile, applyOptions{
+			Verify:  flags&ApplyFlagVerify != 0,
+			Persist: flags&ApplyFlagPersist != 0,
+		})
+	})
+	if err != nil {
+		return dbusError(err)
+	}
+
+	_ = s.conn.Emit(servicePath, serviceInterface+".ProfileApplied", name)
+	return nil
+}
+
+// SaveProfile saves the current layout as the named profile.
+func (s *dbusService) SaveProfile(name string) *dbus.Error {
+	var profile common.Profile
+	err := s.locked(func() error {
+		monitors, err := s.session.ScreenStates()
+		if err != nil {
+			return err
+		}
+		profile, err = layoutProfile(s.session, monitors)
+		if err != nil {
+			return err
+		}
+		// Like the save command, save the layout even without the
+		// display settings
+		if err := captureDisplaySettings(s.session, &profile); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return dbusError(err)
+	}
+
+	err = common.SaveProfile(name, profile)
+	if err != nil {
+		return dbusError(err)
+	}
+	return nil
+}
+
+// dbusError converts the error into a d-bus error whose name is derived from
+// the error kind, e.g. io.github.jclc.Waylander.Error.ProfileNotFound.
+func dbusError(err error) *dbus.Error {
+	_, kind := exitStatus(err)
+
+	var name strings.Builder
+	for _, part := range strings.Split(kind, "_") {
+		if part != "" {
+			name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+
+	return dbus.NewError(serviceErrorName+"."+name.String(), []any{err.Error()})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jclc/waylander/common"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestBus runs a private dbus-daemon and returns its address.
+func startTestBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(config, []byte(strings.Replace(testBusConfig, "%s", dir, 1)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("error reading bus address: %s", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// settingsSession is a stub session with a backlight and night light.
+type settingsSession struct {
+	*stubSession
+}
+
+func (s settingsSession) Brightness() (map[string]int, error) {
+	return map[string]int{"eDP-1": 40}, nil
+}
+
+func (s settingsSession) SetBrightness(connector string, percent int) error {
+	return nil
+}
+
+func (s settingsSession) NightLight() (common.NightLight, error) {
+	return common.NightLight{Enabled: true, Temperature: 4000}, nil
+}
+
+func (s settingsSession) SetNightLight(nl common.NightLight) error {
+	return nil
+}
+
+func TestDBusService(t *testing.T) {
+	address := startTestBus(t)
+	tempConfigDir(t)
+
+	serviceConn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serviceConn.Close()
+
+	stub := newStubSession()
+	svc := &dbusService{conn: serviceConn, session: settingsSession{stub}}
+	if err := svc.export(); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := serviceConn.RequestName(serviceName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("error requesting name: %v, reply %d", err, reply)
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface(serviceInterface),
+		dbus.WithMatchMember("ProfileApplied"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	obj := conn.Object(serviceName, servicePath)
+
+	var profiles []string
+	if err := obj.Call(serviceInterface+".ListProfiles", 0).Store(&profiles); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("got profiles %v, want none", profiles)
+	}
+
+	// Saving records the same details as the save command
+	if err := obj.Call(serviceInterface+".SaveProfile", 0, "panel").Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Call(serviceInterface+".ListProfiles", 0).Store(&profiles); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(profiles, []string{"panel"}) {
+		t.Errorf("got profiles %v, want [panel]", profiles)
+	}
+	saved, err := common.LoadProfile("panel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.LayoutMode != common.LayoutModeLogical ||
+		!slices.Equal(saved.Disabled, []string{"HDMI-1"}) ||
+		saved.Brightness["eDP-1"] != 40 ||
+		saved.NightLight == nil || saved.NightLight.Temperature != 4000 {
+		t.Errorf("got saved profile %+v", saved)
+	}
+
+	dual := common.Profile{Monitors: []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"eDP-1": stubPanelMode}, Scale: 1, Primary: true},
+		{Outputs: map[string]common.Mode{"HDMI-1": stubTVMode}, Scale: 2, Offset: common.Rect{X: 1920}},
+	}}
+	if err := common.SaveProfile("dual", dual); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Call(serviceInterface+".ApplyProfile", 0, "dual", uint32(0)).Err; err != nil {
+		t.Fatal(err)
+	}
+	if applied := stub.appliedProfiles(); len(applied) != 1 || len(applied[0].Monitors) != 2 {
+		t.Errorf("got applied profiles %+v", applied)
+	}
+	select {
+	case sig := <-signals:
+		if sig.Name != serviceInterface+".ProfileApplied" ||
+			len(sig.Body) != 1 || sig.Body[0] != "dual" {
+			t.Errorf("got signal %s %v", sig.Name, sig.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("ProfileApplied was not emitted")
+	}
+
+	// Errors are named after the error kinds
+	errorTests := []struct {
+		method string
+		args   []any
+		want   string
+	}{
+		{"ApplyProfile", []any{"missing", uint32(0)}, "ProfileNotFound"},
+		{"SaveProfile", []any{"bad/name"}, "Usage"},
+	}
+	for _, tt := range errorTests {
+		err := obj.Call(serviceInterface+"."+tt.method, 0, tt.args...).Err
+		var derr dbus.Error
+		if !errors.As(err, &derr) || derr.Name != serviceErrorName+"."+tt.want {
+			t.Errorf("%s%v: got error %#v, want %s", tt.method, tt.args, err, tt.want)
+		}
+	}
+}
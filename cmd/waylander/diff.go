@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jclc/waylander/common"
+)
+
+const (
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorBold  = "\x1b[1m"
+	colorReset = "\x1b[0m"
+)
+
+// currentLabel names the current layout when diffing against it.
+const currentLabel = "current"
+
+// diffResult is the output of the diff command.
+type diffResult struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Differences []common.Difference `json:"differences"`
+}
+
+// RunDiff compares two profiles, or a profile and the current layout, per
+// output.
+func RunDiff(args []string) error {
+	set := newFlagSet()
+	format := outputFlag(set, "")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+	if *format != "" {
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return usagef("specify one or two profiles to compare")
+	}
+
+	from, err := common.LoadProfile(args[0])
+	if err != nil {
+		return err
+	}
+
+	result := diffResult{From: args[0]}
+	var to []common.LogicalMonitor
+	if len(args) == 2 {
+		profile, err := common.LoadProfile(args[1])
+		if err != nil {
+			return err
+		}
+		result.To = args[1]
+		to = common.CloneMonitors(profile.Monitors)
+		common.ConvertLayoutMode(to, profile.LayoutMode, from.LayoutMode)
+	} else {
+		result.To = currentLabel
+		var layoutMode common.LayoutMode
+		err := withSession(func() error {
+			var err error
+			to, err = session.ScreenStates()
+			if err != nil {
+				return fmt.Errorf("error getting current monitor layout: %w", err)
+			}
+			layoutMode, err = session.LayoutMode()
+			if err != nil {
+				return fmt.Errorf("error getting layout mode: %w", err)
+			}
+			// Compare the outputs the profile would be applied to
+			from, err = remapProfile(session, from, nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		from.Monitors = common.CloneMonitors(from.Monitors)
+		common.ConvertLayoutMode(from.Monitors, from.LayoutMode, layoutMode)
+	}
+
+	if len(args) == 2 {
+		result.Differences = common.DiffLayouts(from.Monitors, to)
+	} else {
+		result.Differences = common.DiffCurrent(from.Monitors, to)
+	}
+	if result.Differences == nil {
+		result.Differences = []common.Difference{}
+	}
+
+	if *format != "" {
+		return writeOutput(os.Stdout, *format, &result, func(w *tabwriter.Writer) {
+			writeDiffTable(w, result.Differences, result.From, result.To)
+		})
+	}
+
+	writeDiff(os.Stdout, result, useColor(os.Stdout))
+	return nil
+}
+
+// writeDiff writes the differences grouped by output with the old values in
+// red and the new values in green.
+func writeDiff(w io.Writer, result diffResult, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	fmt.Fprintf(w, "%s %s\n", paint(colorRed, "---"), result.From)
+	fmt.Fprintf(w, "%s %s\n", paint(colorGreen, "+++"), result.To)
+	if len(result.Differences) == 0 {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+
+	output := ""
+	for _, diff := range result.Differences {
+		if diff.Output != output {
+			output = diff.Output
+			fmt.Fprintln(w, paint(colorBold, output))
+		}
+		fmt.Fprintf(w, "  %-12s %s -> %s\n", diff.Field,
+			paint(colorRed, formatDiffValue(diff.From)),
+			paint(colorGreen, formatDiffValue(diff.To)))
+	}
+}
+
+// useColor returns true if the file is a terminal and colors aren't disabled
+// with $NO_COLOR.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	_, _, err := terminalSize(int(f.Fd()))
+	return err == nil
+}
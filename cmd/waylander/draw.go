@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jclc/waylander/common"
+)
+
+// Terminal characters are roughly twice as tall as they are wide
+const charAspect = 2.0
+
+const defaultDrawWidth = 80
+
+func RunDraw(args []string) error {
+	set := newFlagSet()
+	width := set.Int("width", 0, "Width of the drawing in columns")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		return usagef("specify at most one profile to draw")
+	}
+
+	var (
+		monitors   []common.LogicalMonitor
+		layoutMode common.LayoutMode
+	)
+	if len(args) == 1 {
+		profile, err := common.LoadProfile(args[0])
+		if err != nil {
+			return err
+		}
+		monitors = profile.Monitors
+		layoutMode = profile.LayoutMode
+	} else {
+		err := withSession(func() error {
+			var err error
+			monitors, err = session.ScreenStates()
+			if err != nil {
+				return fmt.Errorf("error getting current monitor layout: %w", err)
+			}
+			layoutMode, err = session.LayoutMode()
+			if err != nil {
+				return fmt.Errorf("error getting layout mode: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *width <= 0 {
+		*width = terminalWidth()
+	}
+
+	drawLayout(os.Stdout, monitors, layoutMode, *width)
+	return nil
+}
+
+// terminalWidth returns the width of the terminal from $COLUMNS or the
+// default width.
+func terminalWidth() int {
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return defaultDrawWidth
+}
+
+// monitorLabel returns the lines describing a logical monitor in diagrams.
+func monitorLabel(mon common.LogicalMonitor) []string {
+	connectors := mon.Connectors()
+	name := strings.Join(connectors, " = ")
+	if mon.Primary {
+		name = "* " + name
+	}
+
+	lines := []string{name}
+	if len(connectors) > 0 {
+		lines = append(lines, formatMode(mon.Outputs[connectors[0]]))
+	}
+	details := fmt.Sprintf("scale %g", mon.Scale)
+	if mon.Orientation != common.OrientNormal {
+		details += fmt.Sprintf(", %s", mon.Orientation)
+	}
+	lines = append(lines, details)
+	if len(connectors) > 1 {
+		lines = append(lines, "mirrored")
+	}
+	return lines
+}
+
+// drawLayout draws the logical monitors as boxes scaled to fit the width.
+func drawLayout(output io.Writer, monitors []common.LogicalMonitor, layoutMode common.LayoutMode, width int) {
+	if len(monitors) == 0 {
+		fmt.Fprintln(output, "No active monitors")
+		return
+	}
+
+	for _, line := range layoutCanvas(monitors, layoutMode, width, 0, -1) {
+		fmt.Fprintln(output, line)
+	}
+}
+
+// layoutCanvas draws the logical monitors as boxes scaled to fit the width
+// and, if non-zero, the height. The selected monitor is drawn with a
+// highlighted border. The sizes of the monitors depend on the layout mode.
+func layoutCanvas(monitors []common.LogicalMonitor, layoutMode common.LayoutMode, width, height, selected int) []string {
+	topLeft, bottomRight := common.BoundsIn(monitors, layoutMode)
+	size := bottomRight.Sub(topLeft)
+	if size.X <= 0 || size.Y <= 0 || width < 2 {
+		return []string{"Layout has no area"}
+	}
+
+	ratio := float64(width-1) / float64(size.X)
+	if height > 1 {
+		ratio = min(ratio, float64(height-1)*charAspect/float64(size.Y))
+	}
+	rows := int(math.Round(float64(size.Y)*ratio/charAspect)) + 1
+
+	canvas := make([][]rune, rows)
+	for i := range canvas {
+		canvas[i] = []rune(strings.Repeat(" ", width))
+	}
+
+	toCol := func(x int) int {
+		return int(math.Round(float64(x-topLeft.X) * ratio))
+	}
+	toRow := func(y int) int {
+		return int(math.Round(float64(y-topLeft.Y) * ratio / charAspect))
+	}
+
+	// The selected monitor is drawn last so that its border stays visible
+	order := make([]int, 0, len(monitors))
+	for i := range monitors {
+		if i != selected {
+			order = append(order, i)
+		}
+	}
+	if selected >= 0 && selected < len(monitors) {
+		order = append(order, selected)
+	}
+
+	for _, i := range order {
+		mon := monitors[i]
+		end := mon.Offset.Add(mon.SizeIn(layoutMode))
+		x0, y0 := toCol(mon.Offset.X), toRow(mon.Offset.Y)
+		x1, y1 := toCol(end.X), toRow(end.Y)
+		if i == selected {
+			drawBox(canvas, x0, y0, x1, y1, '=', '#', '#')
+		} else {
+			drawBox(canvas, x0, y0, x1, y1, '-', '|', '+')
+		}
+
+		for j, line := range monitorLabel(mon) {
+			row := y0 + 1 + j
+			if row >= y1 {
+				break
+			}
+			drawText(canvas, x0+2, row, x1-1, line)
+		}
+	}
+
+	lines := make([]string, len(canvas))
+	for i, row := range canvas {
+		lines[i] = strings.TrimRight(string(row), " ")
+	}
+	return lines
+}
+
+func drawBox(canvas [][]rune, x0, y0, x1, y1 int, horizontal, vertical, corner rune) {
+	for x := x0; x <= x1; x++ {
+		setCell(canvas, x, y0, horizontal)
+		setCell(canvas, x, y1, horizontal)
+	}
+	for y := y0; y <= y1; y++ {
+		setCell(canvas, x0, y, vertical)
+		setCell(canvas, x1, y, vertical)
+	}
+	setCell(canvas, x0, y0, corner)
+	setCell(canvas, x1, y0, corner)
+	setCell(canvas, x0, y1, corner)
+	setCell(canvas, x1, y1, corner)
+}
+
+// drawText writes the text starting from x, truncated before the column end.
+func drawText(canvas [][]rune, x, y, end int, text string) {
+	for _, r := range text {
+		if x >= end {
+			return
+		}
+		setCell(canvas, x, y, r)
+		x++
+	}
+}
+
+func setCell(canvas [][]rune, x, y int, r rune) {
+	if y >= 0 && y < len(canvas) && x >= 0 && x < len(canvas[y]) {
+		canvas[y][x] = r
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jclc/waylander/common"
+)
+
+// Exit codes returned by waylander. These are documented in the README and
+// must not be changed as scripts rely on them.
+const (
+	ExitOK                 = 0
+	ExitFailure            = 1
+	ExitUsage              = 2
+	ExitProfileNotFound    = 3
+	ExitInvalidProfile     = 4
+	ExitUnsupportedSession = 5
+	ExitBackendUnavailable = 6
+	ExitNoMatchingMode     = 7
+	ExitSerialMismatch     = 8
+	ExitLocked             = 9
+	ExitHookVetoed         = 10
+	ExitUnsupportedFeature = 11
+)
+
+var errLocked = errors.New("filesystem lock is taken")
+
+// usageError is returned when a command is invoked with invalid arguments.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, a ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// exitStatuses maps errors to exit codes and the error kinds reported in
+// JSON error objects. The first matching entry is used.
+var exitStatuses = []struct {
+	err  error
+	code int
+	kind string
+}{
+	{common.ErrInvalidProfileName, ExitUsage, "usage"},
+	{common.ErrProfileNotFound, ExitProfileNotFound, "profile_not_found"},
+	{common.ErrInvalidProfile, ExitInvalidProfile, "invalid_profile"},
+	{common.ErrUnsupportedSession, ExitUnsupportedSession, "unsupported_session"},
+	{common.ErrBackendUnavailable, ExitBackendUnavailable, "backend_unavailable"},
+	{common.ErrNoMatchingMode, ExitNoMatchingMode, "no_matching_mode"},
+	{common.ErrSerialMismatch, ExitSerialMismatch, "serial_mismatch"},
+	{errLocked, ExitLocked, "locked"},
+	{common.ErrHookVetoed, ExitHookVetoed, "hook_vetoed"},
+	{common.ErrUnsupportedFeature, ExitUnsupportedFeature, "unsupported_feature"},
+}
+
+// exitStatus returns the exit code and error kind for the error.
+func exitStatus(err error) (int, string) {
+	if err == nil {
+		return ExitOK, ""
+	}
+
+	// Help was printed on request
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK, ""
+	}
+
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		return ExitUsage, "usage"
+	}
+	for _, s := range exitStatuses {
+		if errors.Is(err, s.err) {
+			return s.code, s.kind
+		}
+	}
+	return ExitFailure, "error"
+}
+
+// reportError writes the error to the output either as a line of text or as
+// a JSON object and returns the exit code.
+func reportError(output io.Writer, err error, asJSON bool) int {
+	code, kind := exitStatus(err)
+	if code == ExitOK {
+		return code
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(output)
+		_ = enc.Encode(struct {
+			Error    string `json:"error"`
+			Kind     string `json:"kind"`
+			ExitCode int    `json:"exit_code"`
+		}{
+			Error:    err.Error(),
+			Kind:     kind,
+			ExitCode: code,
+		})
+	} else {
+		fmt.Fprintln(output, "Error:", err)
+	}
+
+	return code
+}
+
+// newFlagSet creates a flag set whose errors are reported by Run instead of
+// the flag package.
+func newFlagSet() *flag.FlagSet {
+	set := flag.NewFlagSet("", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+	return set
+}
+
+// parseFlags parses the flags, which may be interspersed with positional
+// arguments, and returns the positional arguments.
+func parseFlags(set *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := set.Parse(args); err != nil {
+			return nil, flagError(set, err)
+		}
+		rest := set.Args()
+		consumed := len(args) - len(rest)
+		// Everything after a terminating "--" is positional
+		if len(rest) == 0 || consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// flagError converts an error from parsing the flags into a usage error. If
+// help was requested, the flags are printed to stdout and flag.ErrHelp is
+// returned, which exits successfully.
+func flagError(set *flag.FlagSet, err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		set.SetOutput(os.Stdout)
+		set.PrintDefaults()
+		return err
+	}
+	return usagef("%s", err)
+}
@@ -2,8 +2,8 @@
 
 package main
 
-func GetLock() bool {
-	return true
+func GetLock() error {
+	return nil
 }
 
 func ReleaseLock() {}
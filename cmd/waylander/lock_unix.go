@@ -3,7 +3,7 @@
 package main
 
 import (
-	"errors"
+	"fmt"
 	"os"
 
 	"golang.org/x/sys/unix"
@@ -18,8 +18,9 @@ var lockFile *os.File
 func GetLock() error {
 	f, _ := os.Create(fileLockPath)
 	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
-		return errors.New(
-			"filesystem lock is taken; if waylander is not currently running, delete " + fileLockPath)
+		return fmt.Errorf(
+			"%w; if waylander is not currently running, delete %s",
+			errLocked, fileLockPath)
 	}
 
 	lockFile = f
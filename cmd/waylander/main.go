@@ -1,18 +1,19 @@
 package main
 
 import (
-	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"slices"
 	"strings"
+	"sync"
+	"text/tabwriter"
 
 	"github.com/alessio/shellescape"
 	"github.com/jclc/waylander/common"
 	"github.com/jclc/waylander/mutter"
+	"github.com/jclc/waylander/pulse"
 )
 
 var (
@@ -23,22 +24,74 @@ func Usage() {
 	fmt.Println("Waylander -- a Wayland screen management tool.")
 	fmt.Println()
 	fmt.Printf(
-		"Usage: %s <command> [args...]\n"+
+		"Usage: %s [-json] <command> [args...]\n"+
+			"\n"+
+			"Options:\n"+
+			"    -json                    Print errors as JSON objects\n"+
 			"\n"+
 			"Commands:\n"+
 			"    help                     Print this help message\n"+
-			"    resources                Show currently connected outputs\n"+
-			"    state                    Show the current configuration\n"+
+			"    resources [opts]         Show currently connected outputs\n"+
+			"      -o <format>            Output format (default json)\n"+
+			"    state [opts]             Show the current configuration\n"+
+			"      -o <format>            Output format (default json)\n"+
+			"    capabilities [opts]      Show the features the desktop supports\n"+
+			"      -o <format>            Output format (default table)\n"+
+			"    current [opts]           Show the profiles matching the current layout\n"+
+			"      -diff                  Show differences to the nearest profile\n"+
+			"      -o <format>            Output format\n"+
 			"    profiles [opts]          List saved profiles\n"+
 			"      -shell                 Print in a shell-friendly format\n"+
-			"    save <profile>           Save current state as a profile\n"+
-			"    show <profile>           Show profile\n"+
+			"      -o <format>            Output format\n"+
+			"    save [opts] <profile>    Save current state as a profile\n"+
+			"      -audio                 Include audio outputs\n"+
+			"    show [opts] <profile>    Show profile\n"+
+			"      -o <format>            Output format\n"+
 			"    apply [opts] <profile>   Apply profile\n"+
 			"      -persist               Make profile persistent\n"+
 			"      -verify                Ask for confirmation\n"+
+			"      -no-hooks              Don't run apply hooks\n"+
+			"      -hook-timeout <dur>    Time each hook may run (default 10s)\n"+
+			"      -force                 Apply even if the layout is already applied\n"+
+			"      -map <from=to,...>     Rename the profile's connectors\n"+
+			"    set [opts] <output> [output opts]...\n"+
+			"                             Change outputs of the current layout\n"+
+			"      -mode <WxH@Hz>         Mode, or preferred\n"+
+			"      -pos <X,Y>             Position\n"+
+			"      -scale <scale>         Scale\n"+
+			"      -rotate <rotation>     normal, 90, 180, 270, flipped...\n"+
+			"      -primary               Make the output primary\n"+
+			"      -vrr <on|off>          Variable refresh rate\n"+
+			"      -off                   Turn the output off\n"+
+			"    cycle [opts] <profile>.. Apply the profile after the current one\n"+
+			"    mirror [opts]            Mirror all outputs\n"+
+			"    extend [opts]            Extend the desktop to all outputs\n"+
+			"      -direction <dir>       right, left, above or below (default right)\n"+
+			"    only [opts] <output>     Only enable the output\n"+
+			"    external [opts]          Only enable the external outputs\n"+
+			"    xrandr [opts] [args]     Change or list outputs with xrandr options\n"+
+			"    diff [opts] <a> [b]      Compare two profiles or a profile and the current layout\n"+
+			"      -o <format>            Output format\n"+
+			"    alias [opts] [name] [monitor]\n"+
+			"                             List or set monitor nicknames\n"+
+			"      -delete                Delete the alias\n"+
+			"      -o <format>            Output format\n"+
 			"    delete <profile>         Delete profile\n"+
 			"    edit <profile>           Edit profile\n"+
+			"    draw [opts] [profile]    Draw the current layout or a profile\n"+
+			"      -width <columns>       Width of the drawing\n"+
+			"    tui                      Edit the layout interactively\n"+
+			"    serve [opts]             Serve a web UI on localhost\n"+
+			"      -port <port>           Port to listen on (default random)\n"+
+			"    dbus-service             Run the io.github.jclc.Waylander d-bus service\n"+
+			"    render [opts] <profile>  Render a profile as an SVG or PNG image\n"+
+			"      -o <file>              Output file (default stdout)\n"+
+			"      -format <svg|png>      Image format (default from extension)\n"+
+			"      -width <pixels>        Width of the image\n"+
+			"      -resources <file>      Resources JSON used for labels\n"+
 			"    debuginfo                Print desktop session internal info\n"+
+			"\n"+
+			"Output formats: table, json, yaml, template='{{...}}'\n"+
 			"", filepath.Base(os.Args[0]))
 }
 
@@ -47,144 +100,298 @@ func main() {
 }
 
 func Run() int {
-	if len(os.Args) <= 1 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+	args := os.Args[1:]
+	var jsonErrors bool
+	for len(args) > 0 && args[0] == "-json" {
+		jsonErrors = true
+		args = args[1:]
+	}
+
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
 		Usage()
-		return 0
+		return ExitOK
 	}
 
-	cmd := os.Args[1]
+	err := runCommand(args[0], args[1:])
+	return reportError(os.Stderr, err, jsonErrors)
+}
+
+func runCommand(cmd string, args []string) error {
 	// Commands that don't require a desktop session
 	switch cmd {
 	case "profiles":
-		return RunProfiles(os.Args[2:])
+		return RunProfiles(args)
 	case "show":
-		return RunShow(os.Args[2:])
+		return RunShow(args)
 	case "edit":
-		return RunEdit(os.Args[2:])
+		return RunEdit(args)
 	case "delete":
-		return RunDelete(os.Args[2:])
-	}
-
-	if err := GetLock(); err != nil {
-		fmt.Println("Fatal error:", err)
-		return 1
-	}
-	defer ReleaseLock()
-
-	var err error
-	session, err = GetDesktopSession()
-	if err != nil {
-		fmt.Println("Error opening desktop session:", err)
-		return 1
+		return RunDelete(args)
+	case "render":
+		return RunRender(args)
+	// Long-running commands take the lock only while using the session
+	case "serve":
+		if wantsHelp(args) {
+			return RunServe(args)
+		}
+		return withUnlockedSession(func() error {
+			return RunServe(args)
+		})
+	case "dbus-service":
+		if wantsHelp(args) {
+			return RunDBusService(args)
+		}
+		return withUnlockedSession(func() error {
+			return RunDBusService(args)
+		})
+	case "draw":
+		// Opens a desktop session only when drawing the current state
+		return RunDraw(args)
+	case "alias":
+		// Opens a desktop session only when resolving a connector
+		return RunAlias(args)
+	case "diff":
+		// Opens a desktop session only when comparing to the current state
+		return RunDiff(args)
 	}
-	defer session.Close()
 
 	// Commands that require a desktop session
+	var run func(args []string) error
 	switch cmd {
 	case "state":
-		return RunState(os.Args[2:])
+		run = RunState
 	case "resources":
-		return RunResources(os.Args[2:])
+		run = RunResources
+	case "capabilities":
+		run = RunCapabilities
+	case "current":
+		run = RunCurrent
 	case "apply":
-		return RunApply(os.Args[2:])
+		run = RunApply
 	case "save":
-		return RunSave(os.Args[2:])
+		run = RunSave
+	case "set":
+		run = RunSet
+	case "cycle":
+		run = RunCycle
+	case "mirror":
+		run = RunMirror
+	case "extend":
+		run = RunExtend
+	case "only":
+		run = RunOnly
+	case "external":
+		run = RunExternal
+	case "xrandr":
+		run = RunXrandr
+	case "tui":
+		run = RunTUI
 	case "debuginfo":
-		return RunDebugInfo(os.Args[2:])
+		run = RunDebugInfo
+	default:
+		return usagef("invalid command '%s'", cmd)
 	}
 
-	fmt.Printf("Invalid command '%s'\n", cmd)
-	return 1
+	// The commands print their usage before using the session, so help
+	// works without a desktop session
+	if wantsHelp(args) {
+		return run(args)
+	}
+	return withSession(func() error {
+		return run(args)
+	})
 }
 
-func GetDesktopSession() (common.DesktopSession, error) {
-	// Detect current desktop session
-	session := os.Getenv("DESKTOP_SESSION")
-	switch session {
-	case "gnome", "gnome-xorg":
-		return mutter.GetDesktopSession()
+// wantsHelp returns true if the arguments ask for the usage of the command.
+func wantsHelp(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--h", "--help":
+			return true
+		}
 	}
-	return nil, fmt.Errorf("unsupported desktop session '%s'", session)
+	return false
 }
 
-func getProfilePath(name string) string {
-	return filepath.Join(common.GetConfigDir(), "profiles",
-		fmt.Sprintf("%s.json", name))
+// withSession acquires the lock and opens the desktop session for the
+// duration of the function.
+func withSession(fn func() error) error {
+	return withLock(func() error {
+		return withUnlockedSession(fn)
+	})
 }
 
-func getProfiles() []string {
-	files, err := os.ReadDir(filepath.Join(common.GetConfigDir(), "profiles"))
-	if os.IsNotExist(err) {
-		return nil
-	} else if err != nil {
-		fmt.Println("Error reading profiles:", err)
-		os.Exit(1)
+// withUnlockedSession opens the desktop session for the duration of the
+// function without acquiring the lock.
+func withUnlockedSession(fn func() error) error {
+	var err error
+	session, err = GetDesktopSession()
+	if err != nil {
+		return fmt.Errorf("error opening desktop session: %w", err)
 	}
+	defer session.Close()
 
-	profiles := make([]string, 0, len(files))
-	for _, f := range files {
-		if f.IsDir() || filepath.Ext(f.Name()) != ".json" || len(f.Name()) <= 5 {
-			continue
-		}
-		profiles = append(profiles, f.Name()[:len(f.Name())-5])
+	return fn()
+}
+
+// sessionLocker serializes the access of long-running commands to the
+// desktop session.
+type sessionLocker struct {
+	mu sync.Mutex
+}
+
+// locked calls the function while holding the filesystem lock so that the
+// command doesn't conflict with other waylander processes.
+func (l *sessionLocker) locked(fn func() error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return withLock(fn)
+}
+
+// withLock holds the filesystem lock for the duration of the function.
+func withLock(fn func() error) error {
+	if err := GetLock(); err != nil {
+		return err
 	}
+	defer ReleaseLock()
 
-	return profiles
+	return fn()
 }
 
-// validProfileName returns false if the given name is not valid for a profile
-func validProfileName(profile string) bool {
-	return len(profile) > 0 && !strings.ContainsAny(profile, "/\\:;\n\t\r")
+func GetDesktopSession() (common.DesktopSession, error) {
+	// Detect current desktop session
+	session := os.Getenv("DESKTOP_SESSION")
+	switch session {
+	case "gnome", "gnome-xorg":
+		return mutter.GetDesktopSession()
+	}
+	return nil, fmt.Errorf("%w '%s'", common.ErrUnsupportedSession, session)
 }
 
-func RunDebugInfo(args []string) int {
+func RunDebugInfo(args []string) error {
+	if _, err := parseFlags(newFlagSet(), args); err != nil {
+		return err
+	}
+
 	err := session.DebugInfo(os.Stdout)
 	if err != nil {
-		fmt.Printf("Error getting debug info: %s", err)
-		return 1
+		return fmt.Errorf("error getting debug info: %w", err)
 	}
-	return 0
+	return nil
 }
 
-func RunResources(args []string) int {
+func RunResources(args []string) error {
+	set := newFlagSet()
+	format := outputFlag(set, formatJSON)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
 	res, err := session.Resources()
 	if err != nil {
-		fmt.Println("Error getting monitor resources:", err)
-		return 1
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	addAliases(res, loadAliases())
+
+	var monitors []common.LogicalMonitor
+	if *format == formatTable {
+		monitors, err = session.ScreenStates()
+		if err != nil {
+			return fmt.Errorf("error getting current monitor layout: %w", err)
+		}
 	}
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(&res)
-	return 0
+
+	return writeOutput(os.Stdout, *format, &res, func(w *tabwriter.Writer) {
+		writeResourcesTable(w, res, monitors)
+	})
 }
 
-func RunState(args []string) int {
+func RunState(args []string) error {
+	set := newFlagSet()
+	format := outputFlag(set, formatJSON)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
 	st, err := session.ScreenStates()
 	if err != nil {
-		fmt.Println("Error getting current monitor layout:", err)
-		return 1
+		return fmt.Errorf("error getting current monitor layout: %w", err)
 	}
 	state := common.State{
 		Monitors: st,
 	}
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(&state)
-	return 0
+
+	var (
+		res     common.Resources
+		aliases common.Aliases
+	)
+	if *format == formatTable {
+		res, err = session.Resources()
+		if err != nil {
+			return fmt.Errorf("error getting monitor resources: %w", err)
+		}
+		aliases = loadAliases()
+	}
+
+	return writeOutput(os.Stdout, *format, &state, func(w *tabwriter.Writer) {
+		writeMonitorTable(w, state.Monitors, res, aliases)
+	})
+}
+
+func RunCapabilities(args []string) error {
+	set := newFlagSet()
+	format := outputFlag(set, formatTable)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	caps, err := session.Capabilities()
+	if err != nil {
+		return fmt.Errorf("error getting capabilities: %w", err)
+	}
+
+	return writeOutput(os.Stdout, *format, &caps, func(w *tabwriter.Writer) {
+		writeCapabilitiesTable(w, caps)
+	})
 }
 
-func RunProfiles(args []string) int {
-	set := flag.NewFlagSet("", flag.ContinueOnError)
+func RunProfiles(args []string) error {
+	set := newFlagSet()
 	var shell bool
 	set.BoolVar(&shell, "shell", false,
 		"Print profile names in a shell-friendly format")
+	format := outputFlag(set, "")
 
-	err := set.Parse(args)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if *format != "" {
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	profiles, err := common.ListProfiles()
 	if err != nil {
-		return 1
+		return err
 	}
 
-	profiles := getProfiles()
+	if *format != "" {
+		return writeOutput(os.Stdout, *format, profiles, func(w *tabwriter.Writer) {
+			writeProfilesTable(w, profiles)
+		})
+	}
 
 	print := fmt.Println
 	if shell {
@@ -196,182 +403,154 @@ func RunProfiles(args []string) int {
 	for _, p := range profiles {
 		_, _ = print(p)
 	}
-	return 0
+	return nil
 }
 
-func RunSave(args []string) int {
+func RunSave(args []string) error {
+	set := newFlagSet()
+	audio := set.Bool("audio", false, "Include the audio outputs")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
 	if len(args) < 1 || args[0] == "" {
-		fmt.Println("Give the profile a name")
-		return 1
+		return usagef("give the profile a name")
 	}
 
 	profileName := strings.TrimSpace(args[0])
 
-	if !validProfileName(profileName) {
-		fmt.Println("Invalid profile name")
-		return 1
+	if !common.ValidProfileName(profileName) {
+		return usagef("invalid profile name '%s'", profileName)
 	}
 
 	monitors, err := session.ScreenStates()
 	if err != nil {
-		fmt.Println("Error getting current layout:", err)
-		return 1
+		return fmt.Errorf("error getting current layout: %w", err)
 	}
 
-	profile := common.Profile{
-		Monitors: monitors,
+	profile, err := layoutProfile(session, monitors)
+	if err != nil {
+		return err
 	}
 
-	common.EnsureConfigDir()
-	file, err := os.Create(getProfilePath(profileName))
-	if err != nil {
-		fmt.Println("Error creating profile:", err)
-		return 1
+	// The layout is still worth saving without the brightness and night
+	// light
+	if err := captureDisplaySettings(session, &profile); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
 	}
-	defer file.Close()
 
-	enc := json.NewEncoder(file)
-	enc.SetIndent("", "  ")
-	err = enc.Encode(profile)
-	if err != nil {
-		fmt.Println("Error saving profile:", err)
-		return 1
+	if *audio {
+		cfg, err := pulse.Capture()
+		if err != nil {
+			return fmt.Errorf("error getting audio outputs: %w", err)
+		}
+		profile.Audio = &cfg
 	}
 
-	return 0
+	return common.SaveProfile(profileName, profile)
 }
 
-func RunShow(args []string) int {
-	if len(args) == 0 {
-		fmt.Println("Specify a profile to show")
-		return 1
-	}
-
-	if !validProfileName(args[0]) {
-		fmt.Println("Invalid profile name")
-		return 1
+func RunShow(args []string) error {
+	set := newFlagSet()
+	format := outputFlag(set, "")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
 	}
 
-	if !slices.Contains(getProfiles(), args[0]) {
-		fmt.Printf("Profile '%s' does not exist\n", args[0])
-		return 1
+	if len(args) == 0 {
+		return usagef("specify a profile to show")
 	}
 
-	profileData, err := os.ReadFile(getProfilePath(args[0]))
+	if *format != "" {
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+		profile, err := common.LoadProfile(args[0])
+		if err != nil {
+			return err
+		}
+		return writeOutput(os.Stdout, *format, &profile, func(w *tabwriter.Writer) {
+			writeMonitorTable(w, profile.Monitors, common.Resources{}, nil)
+			if len(profile.Disabled) > 0 {
+				fmt.Fprintln(w)
+				writeDisabledTable(w, profile.Disabled)
+			}
+			if len(profile.Brightness) > 0 || profile.NightLight != nil {
+				fmt.Fprintln(w)
+				writeDisplaySettingsTable(w, profile)
+			}
+			if profile.Audio != nil {
+				fmt.Fprintln(w)
+				writeAudioTable(w, *profile.Audio)
+			}
+		})
+	}
+
+	profileData, err := common.ReadProfile(args[0])
 	if err != nil {
-		fmt.Println("Error reading profile:", err)
-		return 1
+		return err
 	}
 
 	fmt.Print(string(profileData))
 
-	return 0
+	return nil
 }
 
-func RunEdit(args []string) int {
+func RunEdit(args []string) error {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
-		fmt.Println("$EDITOR not set")
-		return 1
+		return errors.New("$EDITOR not set")
 	}
 
-	if !validProfileName(args[0]) {
-		fmt.Println("Invalid profile name")
-		return 1
+	if len(args) != 1 {
+		return usagef("specify which profile to edit")
 	}
 
-	if len(args) != 1 {
-		fmt.Println("Specify which profile to edit")
-		return 1
+	if !common.ValidProfileName(args[0]) {
+		return usagef("invalid profile name '%s'", args[0])
 	}
 
 	common.EnsureConfigDir()
-	cmd := exec.Command(editor, getProfilePath(args[0]))
+	cmd := exec.Command(editor, common.ProfilePath(args[0]))
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	err := cmd.Run()
 	if err != nil {
-		fmt.Println("Error running editor:", err)
-		return 1
+		return fmt.Errorf("error running editor: %w", err)
 	}
 
-	return 0
+	return nil
 }
 
-func RunApply(args []string) int {
-	set := flag.NewFlagSet("", flag.ContinueOnError)
-	var verify, persist bool
-	set.BoolVar(&persist, "persist", false,
-		"Make profile persistent")
-	set.BoolVar(&verify, "verify", false,
-		"Ask for confirmation")
+func RunApply(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	mapFlag(set, opts)
 
-	err := set.Parse(args)
+	args, err := parseFlags(set, args)
 	if err != nil {
-		return 1
+		return err
 	}
-	args = set.Args()
 
 	if len(args) != 1 {
-		fmt.Println("Specify which profile to apply")
-		return 1
-	}
-
-	if !validProfileName(args[0]) {
-		fmt.Println("Invalid profile name")
-		return 1
+		return usagef("specify which profile to apply")
 	}
 
-	if !slices.Contains(getProfiles(), args[0]) {
-		fmt.Printf("Profile '%s' does not exist\n", args[0])
-		return 1
-	}
-
-	profileData, err := os.ReadFile(getProfilePath(args[0]))
+	profile, err := common.LoadProfile(args[0])
 	if err != nil {
-		fmt.Println("Error reading profile:", err)
-		return 1
+		return err
 	}
 
-	var profile common.Profile
-	err = json.Unmarshal(profileData, &profile)
-	if err != nil {
-		fmt.Println("Error parsing profile:", err)
-		return 1
-	}
-
-	err = session.Apply(profile, verify, persist)
-	if err != nil {
-		fmt.Println("Error applying profile:", err)
-		return 1
-	}
-
-	return 0
+	return applyProfile(session, args[0], profile, *opts)
 }
 
-func RunDelete(args []string) int {
+func RunDelete(args []string) error {
 	if len(args) == 0 {
-		fmt.Println("Specify a profile to delete")
-		return 1
-	}
-
-	if !validProfileName(args[0]) {
-		fmt.Println("Invalid profile name")
-		return 1
-	}
-
-	if !slices.Contains(getProfiles(), args[0]) {
-		fmt.Printf("Profile '%s' does not exist\n", args[0])
-		return 1
-	}
-
-	common.EnsureConfigDir()
-	err := os.Remove(getProfilePath(args[0]))
-	if err != nil {
-		fmt.Println("Error deleting profile:", err)
-		return 1
+		return usagef("specify a profile to delete")
 	}
 
-	return 0
+	return common.DeleteProfile(args[0])
 }
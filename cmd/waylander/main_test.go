@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelpWithoutSession(t *testing.T) {
+	// Opening a session would fail as there is none
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent")
+
+	for _, cmd := range []string{"apply", "set", "xrandr", "cycle", "debuginfo", "serve"} {
+		out := captureStdout(t, func() error {
+			if exit, kind := exitStatus(runCommand(cmd, []string{"-h"})); exit != ExitOK {
+				t.Errorf("%s -h: got exit status %d (%s)", cmd, exit, kind)
+			}
+			return nil
+		})
+		if cmd != "debuginfo" && !strings.Contains(out, "-") {
+			t.Errorf("%s -h: got usage %q", cmd, out)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/jclc/waylander/common"
+	"golang.org/x/exp/maps"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatJSON     = "json"
+	formatYAML     = "yaml"
+	formatTable    = "table"
+	formatTemplate = "template="
+)
+
+const outputUsage = "Output format: table, json, yaml or template='{{...}}'"
+
+// outputFlag registers the -o flag on the flag set.
+func outputFlag(set *flag.FlagSet, def string) *string {
+	return set.String("o", def, outputUsage)
+}
+
+// checkFormat returns a usage error if the output format is not valid.
+func checkFormat(format string) error {
+	switch {
+	case format == formatJSON, format == formatYAML, format == formatTable:
+		return nil
+	case strings.HasPrefix(format, formatTemplate):
+		_, err := parseTemplate(format)
+		return err
+	}
+	return usagef("invalid output format '%s'", format)
+}
+
+func parseTemplate(format string) (*template.Template, error) {
+	tmpl, err := template.New("output").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(strings.TrimPrefix(format, formatTemplate))
+	if err != nil {
+		return nil, usagef("invalid template: %s", err)
+	}
+	return tmpl, nil
+}
+
+// writeOutput writes the value in the requested format. The table function
+// is used for the table format.
+func writeOutput(output io.Writer, format string, v any, table func(w *tabwriter.Writer)) error {
+	switch {
+	case format == formatJSON:
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case format == formatYAML:
+		data, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = output.Write(data)
+		return err
+	case format == formatTable:
+		w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case strings.HasPrefix(format, formatTemplate):
+		tmpl, err := parseTemplate(format)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(output, v)
+	}
+	return usagef("invalid output format '%s'", format)
+}
+
+// formatMode formats a mode in a compact form, e.g. 1920x1080@60.00.
+func formatMode(mode common.Mode) string {
+	if mode.Dimensions.X == 0 && mode.Dimensions.Y == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dx%d@%.2f",
+		mode.Dimensions.X, mode.Dimensions.Y, mode.Frequency)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// formatVRR formats the VRR status of an output. Unsupported outputs are
+// marked as n/a when the resources are known.
+func formatVRR(mon common.LogicalMonitor, phys common.PhysicalMonitor, known bool) string {
+	return formatFeature(mon, phys, known,
+		common.PropertyVRREnabled, common.PropertyVRRSupported)
+}
+
+// formatFeature formats the status of an optional feature of an output.
+func formatFeature(mon common.LogicalMonitor, phys common.PhysicalMonitor, known bool, enabled, supported string) string {
+	if known && !common.GetProperty[bool](phys.Properties, supported) {
+		return "n/a"
+	}
+	if common.GetProperty[bool](mon.Properties, enabled) {
+		return "on"
+	}
+	return "off"
+}
+
+// writeMonitorTable writes one row per output of the logical monitors. The
+// resources are optional and are used for alias, vendor and preferred mode
+// columns.
+func writeMonitorTable(w *tabwriter.Writer, monitors []common.LogicalMonitor, res common.Resources, aliases common.Aliases) {
+	fmt.Fprintln(w, "CONNECTOR\tALIAS\tVENDOR\tPRODUCT\tMODE\tPREFERRED\tSCALE\tROTATION\tPOSITION\tPRIMARY\tVRR\tCOLOR\tUNDERSCAN\tPRIVACY")
+	for _, mon := range monitors {
+		connectors := maps.Keys(mon.Outputs)
+		slices.Sort(connectors)
+		for _, connector := range connectors {
+			phys, known := res.Monitors[connector]
+			alias := ""
+			if known {
+				alias = aliases.Name(phys)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%g\t%s\t%d,%d\t%s\t%s\t%s\t%s\t%s\n",
+				connector,
+				orDash(alias),
+				orDash(phys.Vendor),
+				orDash(phys.Product),
+				formatMode(mon.Outputs[connector]),
+				formatMode(phys.PreferredMode),
+				mon.Scale,
+				mon.Orientation,
+				mon.Offset.X, mon.Offset.Y,
+				formatBool(mon.Primary),
+				formatVRR(mon, phys, known),
+				orDash(common.GetProperty[string](mon.Properties, common.PropertyColorMode)),
+				formatFeature(mon, phys, known,
+					common.PropertyUnderscanning, common.PropertyUnderscanningSupported),
+				formatFeature(mon, phys, known,
+					common.PropertyPrivacyScreen, common.PropertyPrivacyScreenSupported),
+			)
+		}
+	}
+}
+
+// writeDisabledTable writes the outputs a profile disables.
+func writeDisabledTable(w *tabwriter.Writer, disabled []string) {
+	fmt.Fprintln(w, "DISABLED")
+	for _, output := range disabled {
+		fmt.Fprintln(w, output)
+	}
+}
+
+// writeDisplaySettingsTable writes the brightness and night light of a
+// profile.
+func writeDisplaySettingsTable(w *tabwriter.Writer, profile common.Profile) {
+	fmt.Fprintln(w, "SETTING\tOUTPUT\tVALUE")
+	connectors := maps.Keys(profile.Brightness)
+	slices.Sort(connectors)
+	for _, connector := range connectors {
+		fmt.Fprintf(w, "brightness\t%s\t%d%%\n", connector, profile.Brightness[connector])
+	}
+	if nl := profile.NightLight; nl != nil {
+		value := "off"
+		if nl.Enabled {
+			value = "on"
+		}
+		if nl.Temperature != 0 {
+			value += fmt.Sprintf(" (%dK)", nl.Temperature)
+		}
+		fmt.Fprintf(w, "night light\t-\t%s\n", value)
+	}
+}
+
+// writeAudioTable writes the default sink and the card profiles of a profile.
+func writeAudioTable(w *tabwriter.Writer, audio common.AudioConfig) {
+	fmt.Fprintln(w, "AUDIO\tDEVICE\tDESCRIPTION")
+	if audio.DefaultSink != "" || audio.DefaultSinkDescription != "" {
+		fmt.Fprintf(w, "default sink\t%s\t%s\n",
+			orDash(audio.DefaultSink), orDash(audio.DefaultSinkDescription))
+	}
+	cards := maps.Keys(audio.CardProfiles)
+	slices.Sort(cards)
+	for _, card := range cards {
+		fmt.Fprintf(w, "card profile\t%s\t%s\n", card, audio.CardProfiles[card])
+	}
+}
+
+// writeResourcesTable writes one row per connected output. Outputs that are
+// not part of the current layout are shown as off.
+func writeResourcesTable(w *tabwriter.Writer, res common.Resources, monitors []common.LogicalMonitor) {
+	fmt.Fprintln(w, "CONNECTOR\tALIAS\tVENDOR\tPRODUCT\tSERIAL\tCURRENT\tPREFERRED\tMODES\tVRR\tCOLOR MODES\tUNDERSCAN\tPRIVACY")
+	connectors := maps.Keys(res.Monitors)
+	slices.Sort(connectors)
+	for _, connector := range connectors {
+		phys := res.Monitors[connector]
+		current := "off"
+		for _, mon := range monitors {
+			if mode, ok := mon.Outputs[connector]; ok {
+				current = formatMode(mode)
+			}
+		}
+		vrr := formatSupported(phys, common.PropertyVRRSupported)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			connector,
+			orDash(common.GetProperty[string](phys.Properties, common.PropertyAlias)),
+			orDash(phys.Vendor),
+			orDash(phys.Product),
+			orDash(phys.Serial),
+			current,
+			formatMode(phys.PreferredMode),
+			len(phys.Modes),
+			vrr,
+			orDash(strings.Join(common.GetStrings(phys.Properties, common.PropertyColorModesSupported), ",")),
+			formatSupported(phys, common.PropertyUnderscanningSupported),
+			formatSupported(phys, common.PropertyPrivacyScreenSupported),
+		)
+	}
+}
+
+// writeCapabilitiesTable writes one row per capability.
+func writeCapabilitiesTable(w *tabwriter.Writer, caps common.Capabilities) {
+	fmt.Fprintln(w, "CAPABILITY\tSUPPORTED")
+	rows := []struct {
+		name      string
+		supported bool
+	}{
+		{"mirroring", caps.Mirroring},
+		{"fractional scaling", caps.FractionalScaling},
+		{"per-monitor scale", caps.PerMonitorScale},
+		{"vrr", caps.VRR},
+		{"color modes", caps.ColorModes},
+		{"hdr", caps.HDR},
+		{"underscanning", caps.Underscanning},
+		{"privacy screen", caps.PrivacyScreen},
+		{"verify", caps.Verify},
+		{"persistence", caps.Persistence},
+		{"change layout mode", caps.ChangeLayoutMode},
+		{"brightness", caps.Brightness},
+		{"night light", caps.NightLight},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", row.name, formatBool(row.supported))
+	}
+	fmt.Fprintf(w, "layout mode\t%s\n", orDash(string(caps.LayoutMode)))
+}
+
+// writeDiffTable writes one row per difference between two layouts. The
+// labels name the columns of the compared layouts.
+func writeDiffTable(w *tabwriter.Writer, diffs []common.Difference, from, to string) {
+	fmt.Fprintf(w, "OUTPUT\tFIELD\t%s\t%s\n", strings.ToUpper(from), strings.ToUpper(to))
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", diff.Output, diff.Field,
+			formatDiffValue(diff.From), formatDiffValue(diff.To))
+	}
+}
+
+// formatDiffValue formats a value of a common.Difference.
+func formatDiffValue(v any) string {
+	switch v := v.(type) {
+	case common.Mode:
+		return formatMode(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case common.Rect:
+		return fmt.Sprintf("%d,%d", v.X, v.Y)
+	case bool:
+		return formatBool(v)
+	case []string:
+		return orDash(strings.Join(v, ","))
+	case nil:
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+func formatSupported(phys common.PhysicalMonitor, supported string) string {
+	if common.GetProperty[bool](phys.Properties, supported) {
+		return "supported"
+	}
+	return "no"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// toYAML converts the value to YAML through its JSON representation, which
+// keeps the field names and the order of struct fields.
+func toYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeYAMLNode reads one JSON value from the decoder as a YAML node.
+// Objects are decoded as mapping nodes in order to preserve key order.
+func decodeYAMLNode(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if tok == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		// Empty collections are written in the flow style, {} and []
+		for dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{
+					Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string),
+				})
+			}
+			value, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		_, err = dec.Token()
+		return node, err
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(tok)}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(tok.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: tok.String()}, nil
+	case string:
+		// The encoder quotes strings that would be read as another type
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tok}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// writeProfilesTable writes one row per saved profile with the outputs it
+// uses. Profiles that can't be read are marked as invalid.
+func writeProfilesTable(w *tabwriter.Writer, profiles []string) {
+	fmt.Fprintln(w, "PROFILE\tMONITORS\tOUTPUTS")
+	for _, name := range profiles {
+		profile, err := common.LoadProfile(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\tinvalid\n", name)
+			continue
+		}
+		var outputs []string
+		for _, mon := range profile.Monitors {
+			outputs = append(outputs, maps.Keys(mon.Outputs)...)
+		}
+		slices.Sort(outputs)
+		fmt.Fprintf(w, "%s\t%d\t%s\n", name, len(profile.Monitors),
+			strings.Join(outputs, ","))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestToYAML(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+		Tags []any  `json:"tags"`
+	}
+	type outer struct {
+		Zeta  int               `json:"zeta"`
+		Alpha []inner           `json:"alpha"`
+		Empty map[string]string `json:"empty"`
+		None  []string          `json:"none"`
+		Null  *int              `json:"null"`
+	}
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{
+			name: "nested lists and maps keep field order",
+			in: outer{
+				Zeta: 1,
+				Alpha: []inner{
+					{Name: "a", Tags: []any{"x", []any{1, 2}}},
+					{Name: "b", Tags: []any{}},
+				},
+				Empty: map[string]string{},
+				None:  []string{},
+			},
+			want: `zeta: 1
+alpha:
+  - name: a
+    tags:
+      - x
+      - - 1
+        - 2
+  - name: b
+    tags: []
+empty: {}
+none: []
+"null": null
+`,
+		},
+		{
+			name: "empty top level collections",
+			in:   []any{},
+			want: "[]\n",
+		},
+		{
+			name: "numbers and booleans",
+			in:   []any{1.5, -1, true},
+			want: "- 1.5\n- -1\n- true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toYAML(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToYAMLQuotesStrings(t *testing.T) {
+	// Strings that YAML would read as another type or that break the
+	// syntax must stay strings
+	strs := []string{
+		"yes", "no", "on", "off", "y", "n", "true", "null", "~",
+		"-1", "0x10", "0o17", "1e3", ".5", ".inf", "1920x1080",
+		"a: b", "#comment", "- item", "[list]", "{map}", "'quoted'",
+		"\"double\"", "", " padded ", "line\nbreak", "tab\there",
+		"HDMI-A-1", "GSM/LG TV/0x01",
+	}
+
+	for _, s := range strs {
+		data, err := toYAML(map[string]string{"value": s})
+		if err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+		var decoded map[string]any
+		if err := yaml.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%q: invalid YAML %q: %s", s, data, err)
+		}
+		if got, ok := decoded["value"].(string); !ok || got != s {
+			t.Errorf("%q: decoded as %#v from %q", s, decoded["value"], data)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jclc/waylander/common"
+)
+
+func RunMirror(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	return applyPreset(opts, common.MirrorPreset)
+}
+
+func RunExtend(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	dir := set.String("direction", string(common.DirectionRight),
+		"Direction to add outputs in")
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	direction, ok := common.ParseDirection(*dir)
+	if !ok {
+		return usagef("invalid direction '%s'", *dir)
+	}
+
+	return applyPreset(opts, func(res common.Resources, mode common.LayoutMode) (common.Profile, error) {
+		return common.ExtendPreset(res, mode, direction)
+	})
+}
+
+func RunOnly(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return usagef("specify the output to enable")
+	}
+
+	aliases, err := common.LoadAliases()
+	if err != nil {
+		return err
+	}
+
+	return applyPreset(opts, func(res common.Resources, mode common.LayoutMode) (common.Profile, error) {
+		return common.OnlyPreset(res, mode, aliases.Resolve(args[0], res))
+	})
+}
+
+func RunExternal(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	return applyPreset(opts, common.ExternalPreset)
+}
+
+// applyPreset computes the layout from the connected outputs in the current
+// layout mode and applies it.
+func applyPreset(opts *applyOptions, preset func(common.Resources, common.LayoutMode) (common.Profile, error)) error {
+	res, err := session.Resources()
+	if err != nil {
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+
+	profile, err := preset(res, layoutMode)
+	if err != nil {
+		return err
+	}
+
+	return applyProfile(session, "", profile, *opts)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jclc/waylander/common"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	renderPadding    = 16
+	renderLineHeight = 16
+)
+
+var (
+	colorBackground = color.RGBA{0xf6, 0xf6, 0xf6, 0xff}
+	colorMonitor    = color.RGBA{0xd8, 0xe4, 0xf0, 0xff}
+	colorPrimary    = color.RGBA{0xa8, 0xc8, 0xe8, 0xff}
+	colorBorder     = color.RGBA{0x30, 0x40, 0x50, 0xff}
+	colorText       = color.RGBA{0x10, 0x10, 0x10, 0xff}
+)
+
+// renderBox is a logical monitor positioned in image coordinates.
+type renderBox struct {
+	rect    image.Rectangle
+	primary bool
+	labels  []string
+}
+
+func RunRender(args []string) error {
+	set := newFlagSet()
+	outPath := set.String("o", "-", "Output file, - for stdout")
+	format := set.String("format", "", "Output format: svg or png (default from file extension)")
+	width := set.Int("width", 800, "Width of the image in pixels")
+	resPath := set.String("resources", "", "Resources JSON file used for monitor labels")
+	args, err := parseFlags(set, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return usagef("specify which profile to render")
+	}
+	if *width <= 2*renderPadding {
+		return usagef("invalid width %d", *width)
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*outPath), ".")
+		if *format == "" {
+			*format = "svg"
+		}
+	}
+	if *format != "svg" && *format != "png" {
+		return usagef("invalid image format '%s'", *format)
+	}
+
+	profile, err := loadProfileArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	var res common.Resources
+	if *resPath != "" {
+		data, err := os.ReadFile(*resPath)
+		if err != nil {
+			return fmt.Errorf("error reading resources: %w", err)
+		}
+		if err := json.Unmarshal(data, &res); err != nil {
+			return fmt.Errorf("error parsing resources: %w", err)
+		}
+	}
+
+	boxes, size := layoutBoxes(profile.Monitors, profile.LayoutMode, res, *width)
+
+	var output io.Writer = os.Stdout
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("error creating image: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if *format == "png" {
+		err = renderPNG(output, boxes, size)
+	} else {
+		err = renderSVG(output, boxes, size)
+	}
+	if err != nil {
+		return fmt.Errorf("error rendering image: %w", err)
+	}
+	return nil
+}
+
+// loadProfileArg loads a profile by name, or from a file if the argument is
+// a path to a JSON file.
+func loadProfileArg(arg string) (common.Profile, error) {
+	if filepath.Ext(arg) != ".json" && !strings.ContainsRune(arg, filepath.Separator) {
+		return common.LoadProfile(arg)
+	}
+
+	data, err := os.ReadFile(arg)
+	if errors.Is(err, os.ErrNotExist) {
+		return common.Profile{}, &common.ProfileError{Profile: arg, Err: common.ErrProfileNotFound}
+	} else if err != nil {
+		return common.Profile{}, fmt.Errorf("error reading profile: %w", err)
+	}
+
+	var profile common.Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return common.Profile{}, &common.ProfileError{
+			Profile: arg,
+			Err:     fmt.Errorf("%w: %w", common.ErrInvalidProfile, err),
+		}
+	}
+	return profile, nil
+}
+
+// layoutBoxes scales the logical monitors to fit the width and returns them
+// with the size of the image.
+func layoutBoxes(monitors []common.LogicalMonitor, layoutMode common.LayoutMode, res common.Resources, width int) ([]renderBox, image.Point) {
+	topLeft, bottomRight := common.BoundsIn(monitors, layoutMode)
+	size := bottomRight.Sub(topLeft)
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, image.Pt(width, 2*renderPadding)
+	}
+
+	ratio := float64(width-2*renderPadding) / float64(size.X)
+	toImage := func(p common.Rect) image.Point {
+		return image.Pt(
+			renderPadding+int(math.Round(float64(p.X-topLeft.X)*ratio)),
+			renderPadding+int(math.Round(float64(p.Y-topLeft.Y)*ratio)))
+	}
+
+	boxes := make([]renderBox, 0, len(monitors))
+	for _, mon := range monitors {
+		labels := monitorLabel(mon)
+		for _, connector := range mon.Connectors() {
+			phys, ok := res.Monitors[connector]
+			if ok && (phys.Vendor != "" || phys.Product != "") {
+				labels = append(labels, strings.TrimSpace(phys.Vendor+" "+phys.Product))
+			}
+		}
+
+		boxes = append(boxes, renderBox{
+			rect: image.Rectangle{
+				Min: toImage(mon.Offset),
+				Max: toImage(mon.Offset.Add(mon.SizeIn(layoutMode))),
+			},
+			primary: mon.Primary,
+			labels:  labels,
+		})
+	}
+
+	height := int(math.Round(float64(size.Y)*ratio)) + 2*renderPadding
+	return boxes, image.Pt(width, height)
+}
+
+func renderSVG(output io.Writer, boxes []renderBox, size image.Point) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size.X, size.Y, size.X, size.Y)
+	fmt.Fprintf(&b, `  <rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(colorBackground))
+
+	for _, box := range boxes {
+		fill := colorMonitor
+		if box.primary {
+			fill = colorPrimary
+		}
+		fmt.Fprintf(&b, `  <rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s" stroke-width="2"/>`+"\n",
+			box.rect.Min.X, box.rect.Min.Y, box.rect.Dx(), box.rect.Dy(),
+			svgColor(fill), svgColor(colorBorder))
+
+		center := box.rect.Min.X + box.rect.Dx()/2
+		top := box.rect.Min.Y + box.rect.Dy()/2 - len(box.labels)*renderLineHeight/2
+		for i, label := range box.labels {
+			fmt.Fprintf(&b, `  <text x="%d" y="%d" text-anchor="middle" dominant-baseline="hanging" `+
+				`font-family="sans-serif" font-size="13" fill="%s">%s</text>`+"\n",
+				center, top+i*renderLineHeight, svgColor(colorText), html.EscapeString(label))
+		}
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(output, b.String())
+	return err
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func renderPNG(output io.Writer, boxes []renderBox, size image.Point) error {
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(img, img.Bounds(), image.NewUniform(colorBackground), image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	for _, box := range boxes {
+		fill := colorMonitor
+		if box.primary {
+			fill = colorPrimary
+		}
+		draw.Draw(img, box.rect, image.NewUniform(colorBorder), image.Point{}, draw.Src)
+		draw.Draw(img, box.rect.Inset(2), image.NewUniform(fill), image.Point{}, draw.Src)
+
+		// Clip the labels to the monitor
+		drawer := font.Drawer{
+			Dst:  img.SubImage(box.rect.Inset(2)).(*image.RGBA),
+			Src:  image.NewUniform(colorText),
+			Face: face,
+		}
+		center := box.rect.Min.X + box.rect.Dx()/2
+		top := box.rect.Min.Y + box.rect.Dy()/2 - len(box.labels)*renderLineHeight/2
+		for i, label := range box.labels {
+			labelWidth := drawer.MeasureString(label).Round()
+			drawer.Dot = fixed.P(center-labelWidth/2, top+i*renderLineHeight+face.Ascent)
+			drawer.DrawString(label)
+		}
+	}
+
+	return png.Encode(output, img)
+}
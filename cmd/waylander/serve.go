@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jclc/waylander/common"
+)
+
+//go:embed web/index.html
+var webIndex []byte
+
+const tokenHeader = "X-Waylander-Token"
+
+func RunServe(args []string) error {
+	set := newFlagSet()
+	port := set.Int("port", 0, "Port to listen on (default random)")
+	if _, err := parseFlags(set, args); err != nil {
+		return err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return fmt.Errorf("error generating token: %w", err)
+	}
+
+	// Only listen on the loopback interface
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		return fmt.Errorf("error starting server: %w", err)
+	}
+	defer listener.Close()
+
+	fmt.Printf("Serving on http://%s/?token=%s\n", listener.Addr(), token)
+	return http.Serve(listener, newServer(session, token))
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// server is the HTTP interface of the web UI. Requests must carry the token
+// either in the token header or, for the page itself, in the query.
+type server struct {
+	session common.DesktopSession
+	token   string
+	mux     *http.ServeMux
+	sessionLocker
+}
+
+func newServer(session common.DesktopSession, token string) *server {
+	s := &server{
+		session: session,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/api/state", s.handleState)
+	s.mux.HandleFunc("/api/resources", s.handleResources)
+	s.mux.HandleFunc("/api/profiles", s.handleProfiles)
+	s.mux.HandleFunc("/api/profiles/", s.handleProfile)
+	s.mux.HandleFunc("/api/apply", s.handleApply)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(tokenHeader)
+	if r.URL.Path == "/" {
+		token = r.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// applyRequest applies either a saved profile or the given layout.
+type applyRequest struct {
+	Profile string          `json:"profile,omitempty"`
+	Layout  *common.Profile `json:"layout,omitempty"`
+	Persist bool            `json:"persist"`
+}
+
+// stateResponse is the current layout with the layout mode its offsets and
+// sizes are in.
+type stateResponse struct {
+	common.State
+	LayoutMode common.LayoutMode `json:"layout_mode"`
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(webIndex)
+}
+
+func (s *server) handleState(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var state stateResponse
+	err := s.locked(func() (err error) {
+		state.Monitors, err = s.session.ScreenStates()
+		if err != nil {
+			return fmt.Errorf("error getting current monitor layout: %w", err)
+		}
+		state.LayoutMode, err = s.session.LayoutMode()
+		if err != nil {
+			return fmt.Errorf("error getting layout mode: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, state)
+}
+
+func (s *server) handleResources(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var res common.Resources
+	err := s.locked(func() (err error) {
+		res, err = s.session.Resources()
+		return err
+	})
+	if err != nil {
+		writeError(w, fmt.Errorf("error getting monitor resources: %w", err))
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	profiles, err := common.ListProfiles()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if profiles == nil {
+		profiles = []string{}
+	}
+	writeJSON(w, profiles)
+}
+
+func (s *server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/profiles/")
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := common.LoadProfile(name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, profile)
+	case http.MethodPut:
+		var profile common.Profile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			writeError(w, fmt.Errorf("%w: %w", common.ErrInvalidProfile, err))
+			return
+		}
+		// The layout is edited in the current layout mode, and outputs
+		// missing from it are disabled like in saved profiles
+		err := s.locked(func() error {
+			current, err := layoutProfile(s.session, profile.Monitors)
+			if err != nil {
+				return err
+			}
+			if profile.LayoutMode == "" {
+				profile.LayoutMode = current.LayoutMode
+			}
+			if profile.Disabled == nil {
+				profile.Disabled = current.Disabled
+			}
+			return nil
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := common.SaveProfile(name, profile); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := common.DeleteProfile(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *server) handleApply(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req applyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, usagef("invalid request: %s", err))
+		return
+	}
+
+	var name string
+	var profile common.Profile
+	switch {
+	case req.Layout != nil:
+		profile = *req.Layout
+	case req.Profile != "":
+		var err error
+		name = req.Profile
+		profile, err = common.LoadProfile(req.Profile)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	default:
+		writeError(w, usagef("specify a profile or a layout to apply"))
+		return
+	}
+
+	err := s.locked(func() error {
+		return applyProfile(s.session, name, profile, applyOptions{
+			Persist: req.Persist,
+		})
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the error as a JSON object with the same kind as the
+// command line reports.
+func writeError(w http.ResponseWriter, err error) {
+	_, kind := exitStatus(err)
+
+	status := http.StatusInternalServerError
+	var uerr *usageError
+	switch {
+	case errors.Is(err, common.ErrProfileNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, common.ErrInvalidProfile),
+		errors.Is(err, common.ErrInvalidProfileName),
+		errors.Is(err, common.ErrNoMatchingMode),
+		errors.Is(err, common.ErrUnsupportedFeature),
+		errors.As(err, &uerr):
+		status = http.StatusBadRequest
+	case errors.Is(err, common.ErrSerialMismatch),
+		errors.Is(err, common.ErrHookVetoed):
+		status = http.StatusConflict
+	case errors.Is(err, common.ErrBackendUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Kind  string `json:"kind"`
+	}{
+		Error: err.Error(),
+		Kind:  kind,
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T) (*httptest.Server, *stubSession) {
+	t.Helper()
+	tempConfigDir(t)
+	sess := newStubSession()
+	ts := httptest.NewServer(newServer(sess, testToken))
+	t.Cleanup(ts.Close)
+	return ts, sess
+}
+
+// request sends an API request with the token and returns the response
+// status and body.
+func request(t *testing.T, ts *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestServeRejectsInvalidToken(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	for _, token := range []string{"", "wrong"} {
+		if code, _ := request(t, ts, "GET", "/api/state", token, ""); code != http.StatusForbidden {
+			t.Errorf("token %q: got status %d, want %d", token, code, http.StatusForbidden)
+		}
+	}
+	if code, _ := request(t, ts, "GET", "/?token=wrong", "", ""); code != http.StatusForbidden {
+		t.Errorf("index with wrong token: got status %d", code)
+	}
+	if code, _ := request(t, ts, "GET", "/?token="+testToken, "", ""); code != http.StatusOK {
+		t.Errorf("index with token: got status %d", code)
+	}
+}
+
+func TestServeState(t *testing.T) {
+	ts, sess := newTestServer(t)
+
+	code, body := request(t, ts, "GET", "/api/state", testToken, "")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d: %s", code, body)
+	}
+	var state stateResponse
+	if err := json.Unmarshal([]byte(body), &state); err != nil {
+		t.Fatal(err)
+	}
+	if !common.LayoutMatches(common.Profile{Monitors: sess.monitors}, state.Monitors, common.LayoutModeLogical) {
+		t.Errorf("got state %+v, want %+v", state.Monitors, sess.monitors)
+	}
+	if state.LayoutMode != common.LayoutModeLogical {
+		t.Errorf("got layout mode %q", state.LayoutMode)
+	}
+
+	if code, _ := request(t, ts, "POST", "/api/state", testToken, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST state: got status %d", code)
+	}
+}
+
+const testLayout = `{"monitors": [
+	{"outputs": {"eDP-1": "1920x1080 @60.000000"}, "scale": 1, "orientation": "normal", "offset": "0x0", "primary": true},
+	{"outputs": {"HDMI-1": "3840x2160 @60.000000"}, "scale": 2, "orientation": "normal", "offset": "1920x0", "primary": false}
+]}`
+
+func TestServeProfiles(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	if code, body := request(t, ts, "PUT", "/api/profiles/desk", testToken, testLayout); code != http.StatusNoContent {
+		t.Fatalf("PUT: got status %d: %s", code, body)
+	}
+
+	code, body := request(t, ts, "GET", "/api/profiles", testToken, "")
+	if code != http.StatusOK || strings.TrimSpace(body) != `["desk"]` {
+		t.Errorf("list: got status %d, body %s", code, body)
+	}
+
+	code, body = request(t, ts, "GET", "/api/profiles/desk", testToken, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET: got status %d: %s", code, body)
+	}
+	var profile common.Profile
+	if err := json.Unmarshal([]byte(body), &profile); err != nil {
+		t.Fatal(err)
+	}
+	if len(profile.Monitors) != 2 || profile.Monitors[1].Scale != 2 ||
+		profile.LayoutMode != common.LayoutModeLogical || len(profile.Disabled) != 0 {
+		t.Errorf("got profile %+v", profile)
+	}
+
+	// The layout mode and the disabled outputs are filled in like when
+	// saving the current layout
+	if code, body := request(t, ts, "PUT", "/api/profiles/panel", testToken,
+		`{"monitors": [{"outputs": {"eDP-1": "1920x1080 @60.000000"}, "scale": 1, "orientation": "normal", "offset": "0x0", "primary": true}]}`,
+	); code != http.StatusNoContent {
+		t.Fatalf("PUT panel: got status %d: %s", code, body)
+	}
+	panel, err := common.LoadProfile("panel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if panel.LayoutMode != common.LayoutModeLogical || !slices.Equal(panel.Disabled, []string{"HDMI-1"}) {
+		t.Errorf("got panel profile %+v", panel)
+	}
+	if err := common.DeleteProfile("panel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := request(t, ts, "PUT", "/api/profiles/bad", testToken, "{"); code != http.StatusBadRequest {
+		t.Errorf("PUT invalid JSON: got status %d", code)
+	}
+
+	if code, body := request(t, ts, "DELETE", "/api/profiles/desk", testToken, ""); code != http.StatusNoContent {
+		t.Fatalf("DELETE: got status %d: %s", code, body)
+	}
+	code, body = request(t, ts, "GET", "/api/profiles/desk", testToken, "")
+	if code != http.StatusNotFound || !strings.Contains(body, `"profile_not_found"`) {
+		t.Errorf("GET deleted: got status %d, body %s", code, body)
+	}
+}
+
+func TestServeApply(t *testing.T) {
+	ts, sess := newTestServer(t)
+
+	code, body := request(t, ts, "POST", "/api/apply", testToken, `{"layout": `+testLayout+`}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("apply layout: got status %d: %s", code, body)
+	}
+	applied := sess.appliedProfiles()
+	if len(applied) != 1 || len(applied[0].Monitors) != 2 {
+		t.Fatalf("got applied profiles %+v", applied)
+	}
+
+	code, body = request(t, ts, "POST", "/api/apply", testToken, `{"profile": "missing"}`)
+	if code != http.StatusNotFound {
+		t.Errorf("apply missing profile: got status %d: %s", code, body)
+	}
+	code, _ = request(t, ts, "POST", "/api/apply", testToken, `{}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("apply nothing: got status %d", code)
+	}
+
+	// A saved profile is applied by name
+	if code, body := request(t, ts, "PUT", "/api/profiles/panel", testToken,
+		`{"monitors": [{"outputs": {"eDP-1": "1920x1080 @60.000000"}, "scale": 1, "orientation": "normal", "offset": "0x0", "primary": true}]}`,
+	); code != http.StatusNoContent {
+		t.Fatalf("PUT: got status %d: %s", code, body)
+	}
+	code, body = request(t, ts, "POST", "/api/apply", testToken, `{"profile": "panel"}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("apply profile: got status %d: %s", code, body)
+	}
+	if applied := sess.appliedProfiles(); len(applied) != 2 || len(applied[1].Monitors) != 1 {
+		t.Errorf("got applied profiles %+v", applied)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+// stubSession is a desktop session with a laptop panel in use and an
+// external monitor that is off. Applied profiles become the current layout.
+type stubSession struct {
+	mu         sync.Mutex
+	monitors   []common.LogicalMonitor
+	res        common.Resources
+	layoutMode common.LayoutMode
+	caps       common.Capabilities
+	applied    []common.Profile
+}
+
+var (
+	stubPanelMode = common.Mode{Dimensions: common.Rect{X: 1920, Y: 1080}, Frequency: 60}
+	stubTVMode    = common.Mode{Dimensions: common.Rect{X: 3840, Y: 2160}, Frequency: 60}
+)
+
+func newStubSession() *stubSession {
+	return &stubSession{
+		monitors: []common.LogicalMonitor{{
+			Outputs: map[string]common.Mode{"eDP-1": stubPanelMode},
+			Scale:   1,
+			Primary: true,
+		}},
+		res: common.Resources{Monitors: map[string]common.PhysicalMonitor{
+			"eDP-1": {
+				Vendor: "BOE", Product: "Panel", Serial: "1",
+				PreferredMode: stubPanelMode,
+				Modes:         []common.ModeInfo{{Mode: stubPanelMode, PreferredScale: 1, Preferred: true, Current: true}},
+				Properties:    map[string]any{common.PropertyBuiltin: true},
+			},
+			"HDMI-1": {
+				Vendor: "GSM", Product: "LG TV", Serial: "2",
+				PreferredMode: stubTVMode,
+				Modes:         []common.ModeInfo{{Mode: stubTVMode, PreferredScale: 2, Preferred: true}},
+			},
+		}},
+		layoutMode: common.LayoutModeLogical,
+		caps: common.Capabilities{
+			Mirroring: true, FractionalScaling: true, PerMonitorScale: true,
+			LayoutMode: common.LayoutModeLogical,
+		},
+	}
+}
+
+func (s *stubSession) Resources() (common.Resources, error) {
+	return s.res, nil
+}
+
+func (s *stubSession) ScreenStates() ([]common.LogicalMonitor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return common.CloneMonitors(s.monitors), nil
+}
+
+func (s *stubSession) LayoutMode() (common.LayoutMode, error) {
+	return s.layoutMode, nil
+}
+
+func (s *stubSession) Capabilities() (common.Capabilities, error) {
+	return s.caps, nil
+}
+
+func (s *stubSession) Apply(profile common.Profile, verify, persistent bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied = append(s.applied, profile)
+	s.monitors = common.CloneMonitors(profile.Monitors)
+	return nil
+}
+
+func (s *stubSession) Close() {}
+
+func (s *stubSession) DebugInfo(output io.Writer) error {
+	return nil
+}
+
+func (s *stubSession) appliedProfiles() []common.Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied
+}
+
+// tempConfigDir points the config directory to a temporary directory for
+// the duration of the test.
+func tempConfigDir(t *testing.T) {
+	t.Helper()
+	old := common.GetConfigDir()
+	common.SetConfigDir(filepath.Join(t.TempDir(), "waylander"))
+	t.Cleanup(func() { common.SetConfigDir(old) })
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jclc/waylander/common"
+)
+
+// maxTypedFrequencyDeviation is how far a refresh rate given on the command
+// line may be from the actual rate, e.g. 60 for 59.94 Hz
+const maxTypedFrequencyDeviation = 1.0
+
+// outputChange is a change to one output of the current layout. Nil fields
+// are left unchanged.
+type outputChange struct {
+	Output string
+	// Mode is the requested mode. A zero frequency selects the highest
+	// refresh rate.
+	Mode        *common.Mode
+	Preferred   bool
+	Position    *common.Rect
+	Scale       *float64
+	Orientation *common.Orientation
+	Primary     bool
+	VRR         *bool
+	Off         bool
+	// Rate changes the refresh rate of the output's mode if Mode is nil
+	Rate float64
+	// SameAs mirrors the output of the named output
+	SameAs string
+	// RelativeTo places the output next to the named output in the
+	// direction
+	RelativeTo string
+	Direction  common.Direction
+}
+
+// turnsOn returns true if the change turns the output on when it's off.
+func (c outputChange) turnsOn() bool {
+	return c.Preferred || c.Mode != nil || c.Position != nil ||
+		c.SameAs != "" || c.RelativeTo != ""
+}
+
+func RunSet(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+	// The apply options come before the first output
+	if err := set.Parse(args); err != nil {
+		return flagError(set, err)
+	}
+	args = set.Args()
+
+	if len(args) == 0 {
+		return usagef("specify the outputs to change")
+	}
+
+	var changes []outputChange
+	for len(args) > 0 {
+		change, rest, err := parseOutputChange(args)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+		args = rest
+	}
+
+	monitors, err := session.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	res, err := session.Resources()
+	if err != nil {
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+
+	if err := resolveChangeAliases(changes, res); err != nil {
+		return err
+	}
+	monitors, err = applyChanges(monitors, res, layoutMode, changes)
+	if err != nil {
+		return err
+	}
+
+	profile := common.Profile{
+		Monitors:   monitors,
+		LayoutMode: layoutMode,
+		Disabled:   common.DisabledOutputs(res, monitors),
+	}
+	return applyProfile(session, "", profile, *opts)
+}
+
+// parseOutputChange parses an output name followed by its flags and returns
+// the remaining arguments, which start with the next output.
+func parseOutputChange(args []string) (outputChange, []string, error) {
+	change := outputChange{Output: args[0]}
+	if strings.HasPrefix(change.Output, "-") {
+		return change, nil, usagef("expected an output, got '%s'", change.Output)
+	}
+
+	set := newFlagSet()
+	mode := set.String("mode", "", "Mode as WxH@Hz, WxH or preferred")
+	pos := set.String("pos", "", "Position as X,Y")
+	scale := set.Float64("scale", 0, "Scale")
+	rotate := set.String("rotate", "", "Rotation")
+	set.BoolVar(&change.Primary, "primary", false, "Make the output primary")
+	vrr := set.String("vrr", "", "Variable refresh rate, on or off")
+	set.BoolVar(&change.Off, "off", false, "Turn the output off")
+	if err := set.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return change, nil, flagError(set, err)
+		}
+		return change, nil, usagef("%s: %s", change.Output, err)
+	}
+
+	var err error
+	set.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch f.Name {
+		case "mode":
+			if *mode == "preferred" {
+				change.Preferred = true
+				return
+			}
+			var m common.Mode
+			if m, err = parseModeArg(*mode); err == nil {
+				change.Mode = &m
+			}
+		case "pos":
+			var r common.Rect
+			if r, err = parsePositionArg(*pos); err == nil {
+				change.Position = &r
+			}
+		case "scale":
+			if *scale <= 0 {
+				err = usagef("invalid scale %g", *scale)
+				return
+			}
+			change.Scale = scale
+		case "rotate":
+			var o common.Orientation
+			if err = o.UnmarshalText([]byte(*rotate)); err != nil {
+				err = usagef("%s", err)
+				return
+			}
+			change.Orientation = &o
+		case "vrr":
+			var enabled bool
+			switch *vrr {
+			case "on":
+				enabled = true
+			case "off":
+			default:
+				err = usagef("invalid VRR setting '%s', expected on or off", *vrr)
+				return
+			}
+			change.VRR = &enabled
+		}
+	})
+	if err != nil {
+		return change, nil, err
+	}
+
+	return change, set.Args(), nil
+}
+
+// parseModeArg parses a mode in the form WxH@Hz or WxH.
+func parseModeArg(s string) (common.Mode, error) {
+	var mode common.Mode
+	dims, rate, hasRate := strings.Cut(s, "@")
+	_, err := fmt.Sscanf(dims, "%dx%d", &mode.Dimensions.X, &mode.Dimensions.Y)
+	if err != nil {
+		return mode, usagef("invalid mode '%s', expected WxH@Hz", s)
+	}
+	if hasRate {
+		mode.Frequency, err = strconv.ParseFloat(rate, 64)
+		if err != nil || mode.Frequency <= 0 {
+			return mode, usagef("invalid refresh rate '%s'", rate)
+		}
+	}
+	return mode, nil
+}
+
+// parsePositionArg parses a position in the form X,Y.
+func parsePositionArg(s string) (common.Rect, error) {
+	var pos common.Rect
+	x, y, ok := strings.Cut(s, ",")
+	if !ok {
+		return pos, usagef("invalid position '%s', expected X,Y", s)
+	}
+	var err error
+	if pos.X, err = strconv.Atoi(x); err != nil {
+		return pos, usagef("invalid position '%s', expected X,Y", s)
+	}
+	if pos.Y, err = strconv.Atoi(y); err != nil {
+		return pos, usagef("invalid position '%s', expected X,Y", s)
+	}
+	return pos, nil
+}
+
+// resolveChangeAliases replaces the aliases in the changes with the
+// connectors of their monitors, and connector names spelled differently than
+// the connected outputs, e.g. HDMI1 for HDMI-A-1, with the connected outputs.
+func resolveChangeAliases(changes []outputChange, res common.Resources) error {
+	aliases, err := common.LoadAliases()
+	if err != nil {
+		return err
+	}
+	resolve := func(name string) string {
+		if connector, ok := aliases.Connector(name, res); ok {
+			return connector
+		}
+		if connector, ok := common.MatchConnector(name, res); ok {
+			return connector
+		}
+		return name
+	}
+
+	for i := range changes {
+		change := &changes[i]
+		change.Output = resolve(change.Output)
+		if change.SameAs != "" {
+			change.SameAs = resolve(change.SameAs)
+		}
+		if change.RelativeTo != "" {
+			change.RelativeTo = resolve(change.RelativeTo)
+		}
+	}
+	return nil
+}
+
+// applyChanges returns the monitors with the changes applied. Outputs that
+// are off are added as new logical monitors to the right of the layout.
+// Outputs are mirrored and placed relative to each other after the other
+// changes so that the sizes are known.
+func applyChanges(monitors []common.LogicalMonitor, res common.Resources, layoutMode common.LayoutMode, changes []outputChange) ([]common.LogicalMonitor, error) {
+	monitors = common.CloneMonitors(monitors)
+
+	for _, change := range changes {
+		phys, ok := res.Monitors[change.Output]
+		if !ok && change.Off {
+			// Like xrandr, turning off a disconnected output does nothing
+			continue
+		} else if !ok {
+			return nil, usagef("output '%s' is not connected", change.Output)
+		}
+
+		i := slices.IndexFunc(monitors, func(mon common.LogicalMonitor) bool {
+			_, ok := mon.Outputs[change.Output]
+			return ok
+		})
+
+		if change.Off {
+			if i >= 0 {
+				delete(monitors[i].Outputs, change.Output)
+				if len(monitors[i].Outputs) == 0 {
+					monitors = slices.Delete(monitors, i, i+1)
+				}
+			}
+			continue
+		}
+
+		if i < 0 {
+			// Like xrandr, only a mode or a position turns the output on.
+			// Other changes to an output that is off do nothing.
+			if !change.turnsOn() {
+				continue
+			}
+			// Turn the output on in its preferred mode
+			_, bottomRight := common.BoundsIn(monitors, layoutMode)
+			info, _ := phys.FindMode(phys.PreferredMode)
+			scale := info.PreferredScale
+			if scale <= 0 {
+				scale = 1
+			}
+			monitors = append(monitors, common.LogicalMonitor{
+				Outputs: map[string]common.Mode{change.Output: phys.PreferredMode},
+				Scale:   scale,
+				Offset:  common.Rect{X: bottomRight.X},
+				Primary: len(monitors) == 0,
+			})
+			i = len(monitors) - 1
+		}
+		mon := &monitors[i]
+
+		switch {
+		case change.Preferred:
+			mon.Outputs[change.Output] = phys.PreferredMode
+		case change.Mode != nil:
+			mode, err := findTypedMode(phys, change.Output, *change.Mode)
+			if err != nil {
+				return nil, err
+			}
+			mon.Outputs[change.Output] = mode
+		case change.Rate != 0:
+			mode := mon.Outputs[change.Output]
+			mode.Frequency = change.Rate
+			mode, err := findTypedMode(phys, change.Output, mode)
+			if err != nil {
+				return nil, err
+			}
+			mon.Outputs[change.Output] = mode
+		}
+		if change.Position != nil {
+			mon.Offset = *change.Position
+		}
+		if change.Scale != nil {
+			mon.Scale = *change.Scale
+		}
+		if change.Orientation != nil {
+			mon.Orientation = *change.Orientation
+		}
+		if change.VRR != nil {
+			if mon.Properties == nil {
+				mon.Properties = map[string]any{}
+			}
+			mon.Properties[common.PropertyVRREnabled] = *change.VRR
+		}
+		if change.Primary {
+			for j := range monitors {
+				monitors[j].Primary = j == i
+			}
+		}
+	}
+
+	for _, change := range changes {
+		var err error
+		switch {
+		case change.Off:
+		case change.SameAs != "":
+			monitors, err = mirrorOutput(monitors, res, change.Output, change.SameAs)
+		case change.RelativeTo != "":
+			err = placeOutput(monitors, layoutMode, change.Output, change.RelativeTo, change.Direction)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	common.NormalizeOffsets(monitors)
+
+	if len(monitors) == 0 {
+		return nil, usagef("cannot turn off all outputs")
+	}
+	// Keep a primary monitor if the primary output was turned off
+	if !slices.ContainsFunc(monitors, func(mon common.LogicalMonitor) bool {
+		return mon.Primary
+	}) {
+		monitors[0].Primary = true
+	}
+	return monitors, nil
+}
+
+// monitorIndex returns the index of the logical monitor with the output.
+func monitorIndex(monitors []common.LogicalMonitor, output string) (int, error) {
+	i := slices.IndexFunc(monitors, func(mon common.LogicalMonitor) bool {
+		_, ok := mon.Outputs[output]
+		return ok
+	})
+	if i < 0 {
+		return i, usagef("output '%s' is off", output)
+	}
+	return i, nil
+}
+
+// mirrorOutput moves the output to the logical monitor of the target output
+// using a mode with the same dimensions as the target's.
+func mirrorOutput(monitors []common.LogicalMonitor, res common.Resources, output, target string) ([]common.LogicalMonitor, error) {
+	t, err := monitorIndex(monitors, target)
+	if err != nil {
+		return nil, err
+	}
+	dims := monitors[t].Outputs[target].Dimensions
+	mode, err := findTypedMode(res.Monitors[output], output, common.Mode{Dimensions: dims})
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := monitorIndex(monitors, output)
+	if err != nil {
+		return nil, err
+	}
+	if i == t {
+		return monitors, nil
+	}
+	monitors[t].Outputs[output] = mode
+	monitors[t].Primary = monitors[t].Primary || monitors[i].Primary
+	delete(monitors[i].Outputs, output)
+	if len(monitors[i].Outputs) == 0 {
+		monitors = slices.Delete(monitors, i, i+1)
+	}
+	return monitors, nil
+}
+
+// placeOutput moves the logical monitor of the output next to the target
+// output's monitor, aligned with its top or left edge.
+func placeOutput(monitors []common.LogicalMonitor, layoutMode common.LayoutMode, output, target string, direction common.Direction) error {
+	i, err := monitorIndex(monitors, output)
+	if err != nil {
+		return err
+	}
+	t, err := monitorIndex(monitors, target)
+	if err != nil {
+		return err
+	}
+
+	offset := monitors[t].Offset
+	size := monitors[i].SizeIn(layoutMode)
+	targetSize := monitors[t].SizeIn(layoutMode)
+	switch direction {
+	case common.DirectionRight:
+		offset.X += targetSize.X
+	case common.DirectionLeft:
+		offset.X -= size.X
+	case common.DirectionAbove:
+		offset.Y -= size.Y
+	case common.DirectionBelow:
+		offset.Y += targetSize.Y
+	}
+	monitors[i].Offset = offset
+	return nil
+}
+
+// findTypedMode returns the mode of the monitor matching a mode given on the
+// command line, which may lack the refresh rate or have it rounded.
+func findTypedMode(phys common.PhysicalMonitor, connector string, wanted common.Mode) (common.Mode, error) {
+	var best common.Mode
+	found := false
+	for _, info := range phys.Modes {
+		mode := info.Mode
+		if !mode.Dimensions.Eq(wanted.Dimensions) {
+			continue
+		}
+		var better bool
+		if wanted.Frequency == 0 {
+			better = mode.Frequency > best.Frequency
+		} else {
+			better = math.Abs(mode.Frequency-wanted.Frequency) <
+				math.Abs(best.Frequency-wanted.Frequency)
+		}
+		if !found || better {
+			best = mode
+			found = true
+		}
+	}
+
+	if !found || wanted.Frequency != 0 &&
+		math.Abs(best.Frequency-wanted.Frequency) > maxTypedFrequencyDeviation {
+		return best, &common.ModeError{Connector: connector, Mode: wanted}
+	}
+	return best, nil
+}
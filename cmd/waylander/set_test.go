@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+func TestApplyChangesTurnOnLayoutMode(t *testing.T) {
+	res := newStubSession().res
+	// The TV is 1920 wide when scaled but 3840 in physical pixels
+	monitors := []common.LogicalMonitor{{
+		Outputs: map[string]common.Mode{"HDMI-1": stubTVMode},
+		Scale:   2,
+		Primary: true,
+	}}
+
+	tests := []struct {
+		mode common.LayoutMode
+		want int
+	}{
+		{common.LayoutModeLogical, 1920},
+		{common.LayoutModePhysical, 3840},
+	}
+	for _, tt := range tests {
+		got, err := applyChanges(monitors, res, tt.mode, []outputChange{{Output: "eDP-1", Preferred: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[1].Offset != (common.Rect{X: tt.want}) {
+			t.Errorf("%s: got %+v, want eDP-1 at %d", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestApplyChangesOffDisconnected(t *testing.T) {
+	sess := newStubSession()
+	got, err := applyChanges(sess.monitors, sess.res, sess.layoutMode,
+		[]outputChange{{Output: "DP-3", Off: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !common.LayoutMatches(common.Profile{Monitors: sess.monitors}, got, sess.layoutMode) {
+		t.Errorf("got %+v, want unchanged layout", got)
+	}
+
+	_, err = applyChanges(sess.monitors, sess.res, sess.layoutMode,
+		[]outputChange{{Output: "DP-3", Preferred: true}})
+	if exit, _ := exitStatus(err); exit != ExitUsage {
+		t.Errorf("turning on a disconnected output: got error %v", err)
+	}
+}
+
+func TestApplyChangesBareOutput(t *testing.T) {
+	sess := newStubSession()
+
+	// Like in xrandr, naming the TV doesn't turn it on, and neither do
+	// changes that don't place it
+	for _, args := range [][]string{
+		{"--output", "HDMI-1"},
+		{"--output", "HDMI-1", "--scale", "0.5x0.5", "--rotate", "left"},
+	} {
+		_, changes, _, err := parseXrandrArgs(args, newFlagSet())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := applyChanges(sess.monitors, sess.res, sess.layoutMode, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !common.LayoutMatches(common.Profile{Monitors: sess.monitors}, got, sess.layoutMode) {
+			t.Errorf("%v: got %+v, want unchanged layout", args, got)
+		}
+	}
+
+	_, changes, _, err := parseXrandrArgs([]string{"--output", "HDMI-1", "--auto"}, newFlagSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := applyChanges(sess.monitors, sess.res, sess.layoutMode, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("--auto: got %+v, want HDMI-1 on", got)
+	}
+}
+
+func TestResolveChangeAliases(t *testing.T) {
+	tempConfigDir(t)
+	res := common.Resources{Monitors: map[string]common.PhysicalMonitor{
+		"eDP-1":    {Vendor: "BOE", Product: "Panel", Serial: "1"},
+		"HDMI-A-1": {Vendor: "GSM", Product: "LG TV", Serial: "2"},
+	}}
+	if err := common.SaveAliases(common.Aliases{"tv": "GSM/LG TV/2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []outputChange{
+		{Output: "HDMI1", SameAs: "eDP-1"},
+		{Output: "HDMI-1", RelativeTo: "edp1"},
+		{Output: "tv"},
+		{Output: "DP-3"},
+	}
+	if err := resolveChangeAliases(changes, res); err != nil {
+		t.Fatal(err)
+	}
+	want := []outputChange{
+		{Output: "HDMI-A-1", SameAs: "eDP-1"},
+		{Output: "HDMI-A-1", RelativeTo: "eDP-1"},
+		{Output: "HDMI-A-1"},
+		{Output: "DP-3"},
+	}
+	for i := range want {
+		if changes[i].Output != want[i].Output || changes[i].SameAs != want[i].SameAs ||
+			changes[i].RelativeTo != want[i].RelativeTo {
+			t.Errorf("change %d: got %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jclc/waylander/common"
+)
+
+const (
+	tuiRevertTimeout = 15 * time.Second
+	tuiPanelLines    = 7
+)
+
+// Scales offered by the editor. The backend snaps them to the closest scale
+// the mode supports.
+var tuiScales = []float64{1, 1.25, 1.5, 1.75, 2, 2.5, 3}
+
+const (
+	keyUp       = "\x1b[A"
+	keyDown     = "\x1b[B"
+	keyRight    = "\x1b[C"
+	keyLeft     = "\x1b[D"
+	keyTab      = "\t"
+	keyShiftTab = "\x1b[Z"
+	keyEscape   = "\x1b"
+	keyCtrlC    = "\x03"
+	keyEnter    = "\r"
+	keyBack     = "\x7f"
+)
+
+// editor is the state of the interactive layout editor.
+type editor struct {
+	output     io.Writer
+	keys       chan string
+	res        common.Resources
+	layoutMode common.LayoutMode
+	original   []common.LogicalMonitor
+	monitors   []common.LogicalMonitor
+	selected   int
+	message    string
+}
+
+func RunTUI(args []string) error {
+	if _, err := parseFlags(newFlagSet(), args); err != nil {
+		return err
+	}
+
+	monitors, err := session.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	if len(monitors) == 0 {
+		return errors.New("no active monitors")
+	}
+	res, err := session.Resources()
+	if err != nil {
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("error setting up terminal: %w", err)
+	}
+	defer restore()
+
+	// Use the alternate screen and hide the cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	ed := &editor{
+		output:     os.Stdout,
+		keys:       make(chan string),
+		res:        res,
+		layoutMode: layoutMode,
+		original:   common.CloneMonitors(monitors),
+		monitors:   common.CloneMonitors(monitors),
+	}
+	go ed.readKeys(os.Stdin)
+	ed.run()
+	return nil
+}
+
+func (ed *editor) readKeys(input io.Reader) {
+	buf := make([]byte, 16)
+	for {
+		n, err := input.Read(buf)
+		if err != nil {
+			close(ed.keys)
+			return
+		}
+		ed.keys <- string(buf[:n])
+	}
+}
+
+func (ed *editor) run() {
+	for {
+		ed.draw()
+		key, ok := <-ed.keys
+		if !ok {
+			return
+		}
+		ed.message = ""
+
+		switch key {
+		case "q", keyEscape, keyCtrlC:
+			return
+		case keyTab:
+			ed.selected = (ed.selected + 1) % len(ed.monitors)
+		case keyShiftTab:
+			ed.selected = (ed.selected + len(ed.monitors) - 1) % len(ed.monitors)
+		case keyLeft:
+			ed.move(-1, 0)
+		case keyRight:
+			ed.move(1, 0)
+		case keyUp:
+			ed.move(0, -1)
+		case keyDown:
+			ed.move(0, 1)
+		case "m":
+			ed.cycleMode(1)
+		case "M":
+			ed.cycleMode(-1)
+		case "s":
+			ed.cycleScale(1)
+		case "S":
+			ed.cycleScale(-1)
+		case "r":
+			ed.rotate(1)
+		case "R":
+			ed.rotate(-1)
+		case "p":
+			ed.setPrimary()
+		case "v":
+			ed.toggleVRR()
+		case "c":
+			ed.toggleMirror()
+		case "a":
+			ed.preview()
+		case "w":
+			ed.save()
+		}
+	}
+}
+
+func (ed *editor) draw() {
+	width, height, err := terminalSize(int(os.Stdin.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = defaultDrawWidth, 24
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for _, line := range layoutCanvas(ed.monitors, ed.layoutMode, width, height-tuiPanelLines, ed.selected) {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	mon := ed.monitors[ed.selected]
+	connectors := mon.Connectors()
+	title := fmt.Sprintf("Monitor %d/%d: %s", ed.selected+1, len(ed.monitors),
+		strings.Join(connectors, " = "))
+	if mon.Primary {
+		title += " (primary)"
+	}
+	b.WriteString("\r\n" + title + "\r\n")
+	fmt.Fprintf(&b, "Mode %s  Scale %g  Rotation %s  Position %d,%d  VRR %s\r\n",
+		formatMode(mon.Outputs[connectors[0]]), mon.Scale, mon.Orientation,
+		mon.Offset.X, mon.Offset.Y,
+		formatVRR(mon, ed.res.Monitors[connectors[0]], true))
+	b.WriteString("Tab: select  Arrows: move  m/M: mode  s/S: scale  r/R: rotate  c: mirror  p: primary  v: VRR\r\n")
+	b.WriteString("a: apply with preview  w: save as profile  q: quit\r\n")
+	if anyOverlap(ed.monitors, ed.layoutMode) {
+		b.WriteString("Warning: monitors overlap\r\n")
+	}
+	b.WriteString(ed.message)
+
+	_, _ = io.WriteString(ed.output, b.String())
+}
+
+func anyOverlap(monitors []common.LogicalMonitor, layoutMode common.LayoutMode) bool {
+	for i := range monitors {
+		for j := i + 1; j < len(monitors); j++ {
+			if common.OverlapsIn(monitors[i], monitors[j], layoutMode) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// move moves the selected monitor to the next position in the direction
+// where one of its edges lines up with an edge of another monitor.
+func (ed *editor) move(dx, dy int) {
+	mon := &ed.monitors[ed.selected]
+	size := mon.SizeIn(ed.layoutMode)
+
+	var xEdges, yEdges []int
+	for i, other := range ed.monitors {
+		if i == ed.selected {
+			continue
+		}
+		end := other.Offset.Add(other.SizeIn(ed.layoutMode))
+		xEdges = append(xEdges, other.Offset.X, end.X)
+		yEdges = append(yEdges, other.Offset.Y, end.Y)
+	}
+
+	if dx != 0 {
+		mon.Offset.X = snapPosition(mon.Offset.X, size.X, dx, xEdges)
+	}
+	if dy != 0 {
+		mon.Offset.Y = snapPosition(mon.Offset.Y, size.Y, dy, yEdges)
+	}
+}
+
+// snapPosition returns the closest position in the direction where either
+// the start or the end of a span lines up with one of the edges. If there is
+// none, the span is moved by a quarter of its size.
+func snapPosition(pos, size, dir int, edges []int) int {
+	best := pos + dir*max(size/4, 1)
+	found := false
+	for _, edge := range edges {
+		for _, candidate := range []int{edge, edge - size} {
+			dist := (candidate - pos) * dir
+			if dist > 0 && (!found || dist < (best-pos)*dir) {
+				best = candidate
+				found = true
+			}
+		}
+	}
+	return best
+}
+
+// availableModes returns the modes of the first output of the monitor whose
+// dimensions are supported by all of the outputs.
+func (ed *editor) availableModes(mon common.LogicalMonitor) []common.Mode {
+	connectors := mon.Connectors()
+	var modes []common.Mode
+	for _, info := range ed.res.Monitors[connectors[0]].Modes {
+		ok := true
+		for _, connector := range connectors[1:] {
+			if _, found := ed.res.Monitors[connector].FindMode(info.Mode); !found {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			modes = append(modes, info.Mode)
+		}
+	}
+	return modes
+}
+
+func (ed *editor) setMode(mon *common.LogicalMonitor, mode common.Mode) {
+	for connector := range mon.Outputs {
+		if info, ok := ed.res.Monitors[connector].FindMode(mode); ok {
+			mon.Outputs[connector] = info.Mode
+		}
+	}
+}
+
+func (ed *editor) cycleMode(dir int) {
+	mon := &ed.monitors[ed.selected]
+	modes := ed.availableModes(*mon)
+	if len(modes) == 0 {
+		ed.message = "No modes available"
+		return
+	}
+
+	current := mon.Outputs[mon.Connectors()[0]]
+	i := slices.IndexFunc(modes, func(m common.Mode) bool {
+		return common.ModesEqual(m, current)
+	})
+	i = (i + dir + len(modes)) % len(modes)
+	ed.setMode(mon, modes[i])
+}
+
+// cycleScale switches to the next scale supported by the current mode of
+// the selected monitor, or the common scales if the mode doesn't list any.
+func (ed *editor) cycleScale(dir int) {
+	mon := &ed.monitors[ed.selected]
+	connector := mon.Connectors()[0]
+	scales := tuiScales
+	if info, ok := ed.res.Monitors[connector].FindMode(mon.Outputs[connector]); ok &&
+		len(info.SupportedScales) > 0 {
+		scales = info.SupportedScales
+	}
+	i := slices.Index(scales, common.Closest(scales, mon.Scale))
+	mon.Scale = scales[(i+dir+len(scales))%len(scales)]
+}
+
+// rotate rotates the selected monitor by 90 degrees, keeping it flipped if
+// it was flipped.
+func (ed *editor) rotate(dir int) {
+	mon := &ed.monitors[ed.selected]
+	flipped := mon.Orientation & common.OrientFlipped
+	rotation := (int(mon.Orientation&3) + dir + 4) % 4
+	mon.Orientation = flipped | common.Orientation(rotation)
+}
+
+func (ed *editor) setPrimary() {
+	for i := range ed.monitors {
+		ed.monitors[i].Primary = i == ed.selected
+	}
+}
+
+func (ed *editor) toggleVRR() {
+	mon := &ed.monitors[ed.selected]
+	for connector := range mon.Outputs {
+		if !common.GetProperty[bool](ed.res.Monitors[connector].Properties,
+			common.PropertyVRRSupported) {
+			ed.message = fmt.Sprintf("%s does not support VRR", connector)
+			return
+		}
+	}
+
+	if mon.Properties == nil {
+		mon.Properties = map[string]any{}
+	}
+	mon.Properties[common.PropertyVRREnabled] = !common.GetProperty[bool](
+		mon.Properties, common.PropertyVRREnabled)
+}
+
+// toggleMirror splits a mirrored monitor into separate monitors, or mirrors
+// the selected monitor to the next one.
+func (ed *editor) toggleMirror() {
+	mon := &ed.monitors[ed.selected]
+	connectors := mon.Connectors()
+
+	if len(connectors) > 1 {
+		_, bottomRight := common.BoundsIn(ed.monitors, ed.layoutMode)
+		for i, connector := range connectors[1:] {
+			split := mon.Clone()
+			split.Outputs = map[string]common.Mode{connector: mon.Outputs[connector]}
+			split.Primary = false
+			split.Offset = common.Rect{X: bottomRight.X + i*mon.SizeIn(ed.layoutMode).X, Y: mon.Offset.Y}
+			delete(mon.Outputs, connector)
+			ed.monitors = append(ed.monitors, split)
+		}
+		return
+	}
+
+	if len(ed.monitors) < 2 {
+		ed.message = "Nothing to mirror to"
+		return
+	}
+
+	next := (ed.selected + 1) % len(ed.monitors)
+	merged := mon.Clone()
+	for connector, mode := range ed.monitors[next].Outputs {
+		merged.Outputs[connector] = mode
+	}
+	merged.Primary = mon.Primary || ed.monitors[next].Primary
+
+	// Use the largest mode all outputs support
+	modes := ed.availableModes(merged)
+	if len(modes) == 0 {
+		ed.message = "The outputs have no modes in common"
+		return
+	}
+	best := slices.MaxFunc(modes, func(a, b common.Mode) int {
+		return a.Dimensions.X*a.Dimensions.Y - b.Dimensions.X*b.Dimensions.Y
+	})
+	ed.setMode(&merged, best)
+
+	ed.monitors[ed.selected] = merged
+	ed.monitors = slices.Delete(ed.monitors, next, next+1)
+	if next < ed.selected {
+		ed.selected--
+	}
+}
+
+// profile returns the edited layout as a profile.
+func (ed *editor) profile() common.Profile {
+	monitors := common.CloneMonitors(ed.monitors)
+	common.NormalizeOffsets(monitors)
+	return common.Profile{Monitors: monitors, LayoutMode: ed.layoutMode}
+}
+
+// preview applies the edited layout and reverts to the previous layout
+// unless the user confirms it before the timeout.
+func (ed *editor) preview() {
+	profile := ed.profile()
+	if err := session.Apply(profile, false, false); err != nil {
+		ed.message = fmt.Sprintf("Error applying layout: %s", err)
+		return
+	}
+	ed.monitors = common.CloneMonitors(profile.Monitors)
+
+	deadline := time.Now().Add(tuiRevertTimeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		remaining := time.Until(deadline).Round(time.Second)
+		if remaining <= 0 {
+			break
+		}
+		ed.message = fmt.Sprintf("Keep this layout? (y/n) Reverting in %s", remaining)
+		ed.draw()
+
+		select {
+		case key, ok := <-ed.keys:
+			if ok && key == "y" {
+				ed.original = common.CloneMonitors(profile.Monitors)
+				ed.message = "Layout kept"
+				return
+			}
+			if !ok || key == "n" || key == keyEscape {
+				deadline = time.Now()
+			}
+		case <-ticker.C:
+		}
+	}
+
+	err := session.Apply(common.Profile{Monitors: ed.original}, false, false)
+	if err != nil {
+		ed.message = fmt.Sprintf("Error reverting layout: %s", err)
+		return
+	}
+	ed.message = "Layout reverted"
+}
+
+func (ed *editor) save() {
+	name, ok := ed.prompt("Profile name: ")
+	if !ok {
+		ed.message = ""
+		return
+	}
+
+	name = strings.TrimSpace(name)
+	if !common.ValidProfileName(name) {
+		ed.message = "Invalid profile name"
+		return
+	}
+	profile, err := layoutProfile(session, ed.profile().Monitors)
+	if err != nil {
+		ed.message = err.Error()
+		return
+	}
+	// The layout is still worth saving without the brightness and night
+	// light
+	settingsErr := captureDisplaySettings(session, &profile)
+	if err := common.SaveProfile(name, profile); err != nil {
+		ed.message = err.Error()
+		return
+	}
+	ed.message = fmt.Sprintf("Saved profile '%s'", name)
+	if settingsErr != nil {
+		ed.message += fmt.Sprintf(" without display settings: %s", settingsErr)
+	}
+}
+
+// prompt reads a line of text. It returns false if the input was cancelled.
+func (ed *editor) prompt(label string) (string, bool) {
+	var input []rune
+	for {
+		ed.message = label + string(input) + "_"
+		ed.draw()
+
+		key, ok := <-ed.keys
+		if !ok {
+			return "", false
+		}
+		switch {
+		case key == keyEnter || key == "\n":
+			return string(input), true
+		case key == keyEscape || key == keyCtrlC:
+			return "", false
+		case key == keyBack || key == "\b":
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+			}
+		case key[0] >= ' ' && key[0] != 0x7f:
+			input = append(input, []rune(key)...)
+		}
+	}
+}
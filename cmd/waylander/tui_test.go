@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jclc/waylander/common"
+)
+
+func TestAnyOverlapLayoutMode(t *testing.T) {
+	// The TV ends at 1920 when scaled but at 3840 in physical pixels
+	monitors := []common.LogicalMonitor{
+		{Outputs: map[string]common.Mode{"HDMI-1": stubTVMode}, Scale: 2},
+		{Outputs: map[string]common.Mode{"eDP-1": stubPanelMode}, Scale: 1, Offset: common.Rect{X: 1920}},
+	}
+
+	if anyOverlap(monitors, common.LayoutModeLogical) {
+		t.Error("monitors overlap in the logical layout mode")
+	}
+	if !anyOverlap(monitors, common.LayoutModePhysical) {
+		t.Error("monitors don't overlap in the physical layout mode")
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jclc/waylander/common"
+)
+
+// xrandrRotations maps the xrandr rotations to orientations. xrandr rotates
+// counterclockwise like the Wayland transforms.
+var xrandrRotations = map[string]common.Orientation{
+	"normal":   common.OrientNormal,
+	"left":     common.Orient90,
+	"inverted": common.Orient180,
+	"right":    common.Orient270,
+}
+
+// xrandrDirections maps the xrandr relative position options to directions.
+var xrandrDirections = map[string]common.Direction{
+	"--right-of": common.DirectionRight,
+	"--left-of":  common.DirectionLeft,
+	"--above":    common.DirectionAbove,
+	"--below":    common.DirectionBelow,
+}
+
+func RunXrandr(args []string) error {
+	set := newFlagSet()
+	opts := applyFlags(set)
+
+	query, changes, applyArgs, err := parseXrandrArgs(args, set)
+	if err != nil {
+		return err
+	}
+	if err := set.Parse(applyArgs); err != nil {
+		return flagError(set, err)
+	}
+	if len(set.Args()) > 0 {
+		return usagef("unrecognized option '%s'", set.Args()[0])
+	}
+
+	if len(changes) > 0 {
+		monitors, err := session.ScreenStates()
+		if err != nil {
+			return fmt.Errorf("error getting current monitor layout: %w", err)
+		}
+		res, err := session.Resources()
+		if err != nil {
+			return fmt.Errorf("error getting monitor resources: %w", err)
+		}
+		layoutMode, err := session.LayoutMode()
+		if err != nil {
+			return fmt.Errorf("error getting layout mode: %w", err)
+		}
+
+		if err := resolveChangeAliases(changes, res); err != nil {
+			return err
+		}
+		monitors, err = applyChanges(monitors, res, layoutMode, changes)
+		if err != nil {
+			return err
+		}
+
+		profile := common.Profile{
+			Monitors:   monitors,
+			LayoutMode: layoutMode,
+			Disabled:   common.DisabledOutputs(res, monitors),
+		}
+		if err := applyProfile(session, "", profile, *opts); err != nil {
+			return err
+		}
+	}
+
+	if len(changes) == 0 || query {
+		return xrandrQuery(os.Stdout)
+	}
+	return nil
+}
+
+// parseXrandrArgs parses the xrandr options. Options that aren't xrandr
+// options are returned for the flag set along with their values.
+func parseXrandrArgs(args []string, set *flag.FlagSet) (bool, []outputChange, []string, error) {
+	var (
+		query     bool
+		changes   []outputChange
+		applyArgs []string
+	)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", usagef("%s requires an argument", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch arg {
+		case "-q", "--query":
+			query = true
+			continue
+		case "--output":
+			name, err := value()
+			if err != nil {
+				return false, nil, nil, err
+			}
+			changes = append(changes, outputChange{Output: name})
+			continue
+		}
+
+		if !strings.HasPrefix(arg, "--") || set.Lookup(strings.TrimLeft(arg, "-")) != nil {
+			applyArgs = append(applyArgs, arg)
+			if f := set.Lookup(strings.TrimLeft(arg, "-")); f != nil && !isBoolFlag(f) &&
+				!strings.Contains(arg, "=") && i+1 < len(args) {
+				i++
+				applyArgs = append(applyArgs, args[i])
+			}
+			continue
+		}
+
+		if len(changes) == 0 {
+			return false, nil, nil, usagef("%s requires --output", arg)
+		}
+		change := &changes[len(changes)-1]
+
+		var err error
+		switch arg {
+		case "--auto":
+			change.Preferred = true
+		case "--off":
+			change.Off = true
+		case "--primary":
+			change.Primary = true
+		case "--mode":
+			var s string
+			if s, err = value(); err != nil {
+				break
+			}
+			var mode common.Mode
+			if mode, err = parseModeArg(s); err != nil {
+				break
+			}
+			if change.Rate != 0 {
+				mode.Frequency = change.Rate
+			}
+			change.Mode = &mode
+		case "--rate", "--refresh":
+			var s string
+			if s, err = value(); err != nil {
+				break
+			}
+			rate, perr := strconv.ParseFloat(s, 64)
+			if perr != nil || rate <= 0 {
+				err = usagef("invalid refresh rate '%s'", s)
+				break
+			}
+			change.Rate = rate
+			if change.Mode != nil {
+				change.Mode.Frequency = rate
+			}
+		case "--pos":
+			var s string
+			if s, err = value(); err != nil {
+				break
+			}
+			var pos common.Rect
+			if _, serr := fmt.Sscanf(s, "%dx%d", &pos.X, &pos.Y); serr != nil {
+				err = usagef("invalid position '%s', expected XxY", s)
+				break
+			}
+			change.Position = &pos
+		case "--rotate":
+			var s string
+			if s, err = value(); err != nil {
+				break
+			}
+			o, ok := xrandrRotations[s]
+			if !ok {
+				err = usagef("invalid rotation '%s', expected normal, left, right or inverted", s)
+				break
+			}
+			change.Orientation = &o
+		case "--scale":
+			var s string
+			if s, err = value(); err != nil {
+				break
+			}
+			var scale float64
+			if scale, err = parseXrandrScale(s); err == nil {
+				change.Scale = &scale
+			}
+		case "--same-as":
+			change.SameAs, err = value()
+		case "--right-of", "--left-of", "--above", "--below":
+			change.Direction = xrandrDirections[arg]
+			change.RelativeTo, err = value()
+		default:
+			err = usagef("unsupported option '%s'", arg)
+		}
+		if err != nil {
+			return false, nil, nil, err
+		}
+	}
+
+	return query, changes, applyArgs, nil
+}
+
+// isBoolFlag returns true if the flag doesn't take a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// parseXrandrScale converts an xrandr scale of the form XxY or X to a
+// monitor scale. xrandr scales the framebuffer, so 0.5x0.5 makes everything
+// twice as large like the monitor scale 2.
+func parseXrandrScale(s string) (float64, error) {
+	xs, ys, ok := strings.Cut(s, "x")
+	if !ok {
+		ys = xs
+	}
+	x, errX := strconv.ParseFloat(xs, 64)
+	y, errY := strconv.ParseFloat(ys, 64)
+	if errX != nil || errY != nil || x <= 0 {
+		return 0, usagef("invalid scale '%s', expected XxY", s)
+	}
+	if x != y {
+		return 0, usagef("scale '%s' must be the same in both directions", s)
+	}
+	return 1 / x, nil
+}
+
+// xrandrQuery prints the outputs in the format of xrandr --query.
+func xrandrQuery(w io.Writer) error {
+	monitors, err := session.ScreenStates()
+	if err != nil {
+		return fmt.Errorf("error getting current monitor layout: %w", err)
+	}
+	res, err := session.Resources()
+	if err != nil {
+		return fmt.Errorf("error getting monitor resources: %w", err)
+	}
+	layoutMode, err := session.LayoutMode()
+	if err != nil {
+		return fmt.Errorf("error getting layout mode: %w", err)
+	}
+
+	topLeft, bottomRight := common.BoundsIn(monitors, layoutMode)
+	fmt.Fprintf(w, "Screen 0: current %d x %d\n",
+		bottomRight.X-topLeft.X, bottomRight.Y-topLeft.Y)
+
+	connectors := make([]string, 0, len(res.Monitors))
+	for connector := range res.Monitors {
+		connectors = append(connectors, connector)
+	}
+	slices.Sort(connectors)
+
+	for _, connector := range connectors {
+		phys := res.Monitors[connector]
+		fmt.Fprintf(w, "%s connected", connector)
+
+		i := slices.IndexFunc(monitors, func(mon common.LogicalMonitor) bool {
+			_, ok := mon.Outputs[connector]
+			return ok
+		})
+		var current common.Mode
+		if i >= 0 {
+			mon := monitors[i]
+			current = mon.Outputs[connector]
+			if mon.Primary {
+				fmt.Fprint(w, " primary")
+			}
+			size := mon.SizeIn(layoutMode)
+			fmt.Fprintf(w, " %dx%d+%d+%d", size.X, size.Y, mon.Offset.X, mon.Offset.Y)
+			for name, o := range xrandrRotations {
+				if o == mon.Orientation && o != common.OrientNormal {
+					fmt.Fprintf(w, " %s", name)
+				}
+			}
+		}
+		fmt.Fprintln(w, " (normal left inverted right)")
+
+		writeXrandrModes(w, phys, current)
+	}
+	return nil
+}
+
+// writeXrandrModes prints the modes grouped by their dimensions, marking the
+// current mode with * and the preferred mode with +.
+func writeXrandrModes(w io.Writer, phys common.PhysicalMonitor, current common.Mode) {
+	var dims []common.Rect
+	rates := map[common.Rect][]common.Mode{}
+	for _, info := range phys.Modes {
+		d := info.Mode.Dimensions
+		if _, ok := rates[d]; !ok {
+			dims = append(dims, d)
+		}
+		rates[d] = append(rates[d], info.Mode)
+	}
+
+	for _, d := range dims {
+		fmt.Fprintf(w, "   %-12s", fmt.Sprintf("%dx%d", d.X, d.Y))
+		for _, mode := range rates[d] {
+			currentMark, preferredMark := " ", " "
+			if common.ModesEqual(mode, current) {
+				currentMark = "*"
+			}
+			if common.ModesEqual(mode, phys.PreferredMode) {
+				preferredMark = "+"
+			}
+			fmt.Fprintf(w, " %6.2f%s%s", mode.Frequency, currentMark, preferredMark)
+		}
+		fmt.Fprintln(w)
+	}
+}
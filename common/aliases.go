@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PropertyAlias is the nickname of a physical monitor in the resources
+const PropertyAlias = "alias"
+
+// Aliases maps nicknames to the identities of monitors (see
+// PhysicalMonitor.Identity), so that a monitor can be referred to by its
+// nickname regardless of the connector it's plugged into.
+type Aliases map[string]string
+
+// AliasesPath returns the path of the aliases file in the config.
+func AliasesPath() string {
+	return filepath.Join(configPath, "aliases.json")
+}
+
+// ValidAlias returns false if the name can't be used as an alias. Aliases
+// can't contain slashes so they aren't mistaken for identities.
+func ValidAlias(name string) bool {
+	return len(name) > 0 && !strings.ContainsAny(name, "/=, \n\t\r")
+}
+
+// LoadAliases reads the aliases from the config. They are empty if the file
+// doesn't exist.
+func LoadAliases() (Aliases, error) {
+	data, err := os.ReadFile(AliasesPath())
+	if os.IsNotExist(err) {
+		return Aliases{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading aliases: %w", err)
+	}
+
+	aliases := Aliases{}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("error parsing aliases %s: %w", AliasesPath(), err)
+	}
+	return aliases, nil
+}
+
+// SaveAliases writes the aliases to the config.
+func SaveAliases(aliases Aliases) error {
+	EnsureConfigDir()
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error saving aliases: %w", err)
+	}
+	if err := os.WriteFile(AliasesPath(), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error saving aliases: %w", err)
+	}
+	return nil
+}
+
+// Connector returns the connector of the connected monitor with the alias.
+func (a Aliases) Connector(name string, res Resources) (string, bool) {
+	identity, ok := a[name]
+	if !ok {
+		return "", false
+	}
+	for connector, phys := range res.Monitors {
+		if phys.Identity() == identity {
+			return connector, true
+		}
+	}
+	return "", false
+}
+
+// Name returns the alias of the monitor or an empty string. If the monitor
+// has several aliases, the first in sorted order is returned.
+func (a Aliases) Name(phys PhysicalMonitor) string {
+	var names []string
+	identity := phys.Identity()
+	for name, id := range a {
+		if id == identity {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	slices.Sort(names)
+	return names[0]
+}
+
+// Resolve returns the connector of the connected monitor with the alias, or
+// the name unchanged if it's not an alias of a connected monitor.
+func (a Aliases) Resolve(name string, res Resources) string {
+	if connector, ok := a.Connector(name, res); ok {
+		return connector
+	}
+	return name
+}
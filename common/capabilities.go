@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"math"
+)
+
+// Capabilities tells which features the desktop session supports.
+type Capabilities struct {
+	// Mirroring is true if a logical monitor can have several outputs
+	Mirroring bool `json:"mirroring"`
+	// FractionalScaling is true if scales other than whole numbers are
+	// supported
+	FractionalScaling bool `json:"fractional_scaling"`
+	// PerMonitorScale is true if logical monitors can have different scales
+	PerMonitorScale bool `json:"per_monitor_scale"`
+	VRR             bool `json:"vrr"`
+	// ColorModes is true if the session reports the color modes of the
+	// outputs. Without it color modes are ignored.
+	ColorModes    bool `json:"color_modes"`
+	HDR           bool `json:"hdr"`
+	Underscanning bool `json:"underscanning"`
+	PrivacyScreen bool `json:"privacy_screen"`
+	// Verify is true if applied layouts can be confirmed by the user
+	Verify bool `json:"verify"`
+	// Persistence is true if layouts can be stored by the desktop session
+	Persistence bool `json:"persistence"`
+	// ChangeLayoutMode is true if the session can switch layout modes
+	ChangeLayoutMode bool       `json:"change_layout_mode"`
+	LayoutMode       LayoutMode `json:"layout_mode"`
+	// Brightness and NightLight are true if the session implements
+	// DisplaySettings
+	Brightness bool `json:"brightness"`
+	NightLight bool `json:"night_light"`
+}
+
+// Check returns an error wrapping ErrUnsupportedFeature if the profile or the
+// apply options use a feature the session doesn't support.
+func (c Capabilities) Check(profile Profile, verify, persistent bool) error {
+	unsupported := func(format string, a ...any) error {
+		return fmt.Errorf("%w: "+format, append([]any{ErrUnsupportedFeature}, a...)...)
+	}
+
+	if verify && !c.Verify {
+		return unsupported("verifying layouts")
+	}
+	if persistent && !c.Persistence {
+		return unsupported("persistent layouts")
+	}
+
+	for i, mon := range profile.Monitors {
+		if len(mon.Outputs) > 1 && !c.Mirroring {
+			return unsupported("mirroring (monitor #%d)", i)
+		}
+		if mon.Scale != math.Trunc(mon.Scale) && !c.FractionalScaling {
+			return unsupported("fractional scale %g (monitor #%d)", mon.Scale, i)
+		}
+		if !c.PerMonitorScale && i > 0 &&
+			math.Abs(mon.Scale-profile.Monitors[0].Scale) > Epsilon {
+			return unsupported("different scales per monitor")
+		}
+		if GetProperty[bool](mon.Properties, PropertyVRREnabled) && !c.VRR {
+			return unsupported("VRR (monitor #%d)", i)
+		}
+		if GetProperty[string](mon.Properties, PropertyColorMode) == ColorModeBT2100 &&
+			c.ColorModes && !c.HDR {
+			return unsupported("HDR (monitor #%d)", i)
+		}
+		if GetProperty[bool](mon.Properties, PropertyUnderscanning) && !c.Underscanning {
+			return unsupported("underscanning (monitor #%d)", i)
+		}
+		if GetProperty[bool](mon.Properties, PropertyPrivacyScreen) && !c.PrivacyScreen {
+			return unsupported("privacy screen (monitor #%d)", i)
+		}
+	}
+
+	if len(profile.Brightness) > 0 && !c.Brightness {
+		return unsupported("brightness")
+	}
+	if profile.NightLight != nil && !c.NightLight {
+		return unsupported("night light")
+	}
+	return nil
+}
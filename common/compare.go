@@ -0,0 +1,241 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// LayoutMatches returns true if the profile's layout is the same as the
+// monitors within the tolerances used for applying. Properties are ignored
+// and the offsets are compared relative to the top left corner of the layout.
+func LayoutMatches(profile Profile, monitors []LogicalMonitor, mode LayoutMode) bool {
+	if len(profile.Monitors) != len(monitors) {
+		return false
+	}
+
+	want := CloneMonitors(profile.Monitors)
+	ConvertLayoutMode(want, profile.LayoutMode, mode)
+	NormalizeOffsets(want)
+	have := CloneMonitors(monitors)
+	NormalizeOffsets(have)
+
+	for _, w := range want {
+		i := slices.IndexFunc(have, func(h LogicalMonitor) bool {
+			return monitorsMatch(w, h)
+		})
+		if i < 0 {
+			return false
+		}
+		have = slices.Delete(have, i, i+1)
+	}
+	return true
+}
+
+// ProfileApplied returns true if applying the profile wouldn't change the
+// monitors: the layout matches and the monitors have the properties the
+// profile sets.
+func ProfileApplied(profile Profile, monitors []LogicalMonitor, mode LayoutMode) bool {
+	if !LayoutMatches(profile, monitors, mode) {
+		return false
+	}
+
+	current := outputMonitors(monitors)
+	for _, mon := range profile.Monitors {
+		for connector := range mon.Outputs {
+			have := current[connector]
+			for key, value := range mon.Properties {
+				if !reflect.DeepEqual(have.Properties[key], value) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func monitorsMatch(a, b LogicalMonitor) bool {
+	if len(a.Outputs) != len(b.Outputs) ||
+		math.Abs(a.Scale-b.Scale) > Epsilon ||
+		a.Orientation != b.Orientation ||
+		!a.Offset.Eq(b.Offset) ||
+		a.Primary != b.Primary {
+		return false
+	}
+	for connector, mode := range a.Outputs {
+		other, ok := b.Outputs[connector]
+		if !ok || !mode.Dimensions.Eq(other.Dimensions) ||
+			math.Abs(mode.Frequency-other.Frequency) > MaxAllowedFrequencyDeviation {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckCompatible returns an error if an output the profile configures isn't
+// connected or doesn't support the mode.
+func CheckCompatible(profile Profile, res Resources) error {
+	for _, mon := range profile.Monitors {
+		for _, connector := range mon.Connectors() {
+			phys, ok := res.Monitors[connector]
+			if !ok {
+				return fmt.Errorf("output %s is not connected", connector)
+			}
+			mode := mon.Outputs[connector]
+			info, ok := phys.FindMode(mode)
+			if !ok || math.Abs(info.Mode.Frequency-mode.Frequency) > MaxAllowedFrequencyDeviation {
+				return &ModeError{Connector: connector, Mode: mode}
+			}
+		}
+	}
+	return nil
+}
+
+// Fields of the layout compared by DiffLayouts
+const (
+	DiffEnabled     = "enabled"
+	DiffMode        = "mode"
+	DiffRefresh     = "refresh"
+	DiffScale       = "scale"
+	DiffOrientation = "orientation"
+	DiffPosition    = "position"
+	DiffPrimary     = "primary"
+	DiffMirrors     = "mirrors"
+)
+
+// Difference is a difference in one field of an output between two
+// layouts. From and To hold the values in the layouts, e.g. a Mode for
+// DiffMode and the sorted connectors of the other outputs for DiffMirrors.
+// Differing properties use the name of the property as the field and nil for
+// properties that are not set.
+type Difference struct {
+	Output string `json:"output"`
+	Field  string `json:"field"`
+	From   any    `json:"from"`
+	To     any    `json:"to"`
+}
+
+// DiffLayouts returns the differences between the layouts per output using
+// the tolerances of LayoutMatches. The monitors must be in the same layout
+// mode. The differences are sorted by output.
+func DiffLayouts(from, to []LogicalMonitor) []Difference {
+	from = CloneMonitors(from)
+	NormalizeOffsets(from)
+	to = CloneMonitors(to)
+	NormalizeOffsets(to)
+
+	fromOutputs := outputMonitors(from)
+	toOutputs := outputMonitors(to)
+	connectors := make([]string, 0, len(fromOutputs)+len(toOutputs))
+	for connector := range fromOutputs {
+		connectors = append(connectors, connector)
+	}
+	for connector := range toOutputs {
+		if _, ok := fromOutputs[connector]; !ok {
+			connectors = append(connectors, connector)
+		}
+	}
+	slices.Sort(connectors)
+
+	var diffs []Difference
+	for _, connector := range connectors {
+		a, inFrom := fromOutputs[connector]
+		b, inTo := toOutputs[connector]
+		add := func(field string, from, to any) {
+			diffs = append(diffs, Difference{
+				Output: connector, Field: field, From: from, To: to,
+			})
+		}
+		if !inFrom || !inTo {
+			add(DiffEnabled, inFrom, inTo)
+			continue
+		}
+
+		modeA, modeB := a.Outputs[connector], b.Outputs[connector]
+		if !modeA.Dimensions.Eq(modeB.Dimensions) {
+			add(DiffMode, modeA, modeB)
+		} else if math.Abs(modeA.Frequency-modeB.Frequency) > MaxAllowedFrequencyDeviation {
+			add(DiffRefresh, modeA.Frequency, modeB.Frequency)
+		}
+		if math.Abs(a.Scale-b.Scale) > Epsilon {
+			add(DiffScale, a.Scale, b.Scale)
+		}
+		if a.Orientation != b.Orientation {
+			add(DiffOrientation, a.Orientation, b.Orientation)
+		}
+		if !a.Offset.Eq(b.Offset) {
+			add(DiffPosition, a.Offset, b.Offset)
+		}
+		if a.Primary != b.Primary {
+			add(DiffPrimary, a.Primary, b.Primary)
+		}
+		mirrorsA, mirrorsB := mirrors(a, connector), mirrors(b, connector)
+		if !slices.Equal(mirrorsA, mirrorsB) {
+			add(DiffMirrors, mirrorsA, mirrorsB)
+		}
+
+		keys := make([]string, 0, len(a.Properties)+len(b.Properties))
+		for key := range a.Properties {
+			keys = append(keys, key)
+		}
+		for key := range b.Properties {
+			if _, ok := a.Properties[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if !reflect.DeepEqual(a.Properties[key], b.Properties[key]) {
+				add(key, a.Properties[key], b.Properties[key])
+			}
+		}
+	}
+	return diffs
+}
+
+// DiffCurrent returns the differences from the profile's monitors to the
+// current monitors like DiffLayouts. Properties that the profile doesn't set
+// are ignored like in ProfileApplied, since applying the profile doesn't
+// change them.
+func DiffCurrent(profile, current []LogicalMonitor) []Difference {
+	set := outputMonitors(profile)
+	current = CloneMonitors(current)
+	for i, mon := range current {
+		var props map[string]any
+		for connector := range mon.Outputs {
+			for key := range set[connector].Properties {
+				if value, ok := mon.Properties[key]; ok {
+					if props == nil {
+						props = map[string]any{}
+					}
+					props[key] = value
+				}
+			}
+		}
+		current[i].Properties = props
+	}
+	return DiffLayouts(profile, current)
+}
+
+// outputMonitors maps the connectors to their logical monitors.
+func outputMonitors(monitors []LogicalMonitor) map[string]LogicalMonitor {
+	outputs := make(map[string]LogicalMonitor)
+	for _, mon := range monitors {
+		for connector := range mon.Outputs {
+			outputs[connector] = mon
+		}
+	}
+	return outputs
+}
+
+// mirrors returns the other outputs of the output's logical monitor.
+func mirrors(mon LogicalMonitor, connector string) []string {
+	others := slices.DeleteFunc(mon.Connectors(), func(c string) bool {
+		return c == connector
+	})
+	if len(others) == 0 {
+		return nil
+	}
+	return others
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffCurrent(t *testing.T) {
+	mode := Mode{Dimensions: Rect{X: 1920, Y: 1080}, Frequency: 60}
+	profile := []LogicalMonitor{{
+		Outputs:    map[string]Mode{"HDMI-1": mode},
+		Scale:      1,
+		Primary:    true,
+		Properties: map[string]any{PropertyVRREnabled: true},
+	}}
+	current := func(props map[string]any) []LogicalMonitor {
+		return []LogicalMonitor{{
+			Outputs:    map[string]Mode{"HDMI-1": mode},
+			Scale:      1,
+			Primary:    true,
+			Properties: props,
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		props map[string]any
+		want  []Difference
+	}{
+		{
+			name:  "properties the profile doesn't set are ignored",
+			props: map[string]any{PropertyVRREnabled: true, PropertyUnderscanning: false},
+		},
+		{
+			name:  "set properties are compared",
+			props: map[string]any{PropertyVRREnabled: false},
+			want:  []Difference{{Output: "HDMI-1", Field: PropertyVRREnabled, From: true, To: false}},
+		},
+	}
+	for _, tt := range tests {
+		if got := DiffCurrent(profile, current(tt.props)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Profiles are compared with all properties
+	diffs := DiffLayouts(profile, current(map[string]any{PropertyVRREnabled: true, PropertyUnderscanning: false}))
+	want := []Difference{{Output: "HDMI-1", Field: PropertyUnderscanning, From: nil, To: false}}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("DiffLayouts: got %+v, want %+v", diffs, want)
+	}
+}
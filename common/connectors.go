@@ -0,0 +1,158 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConnectorMap maps the connectors of profiles to the connectors to use
+// instead, e.g. when a dock changes the order of its outputs.
+type ConnectorMap map[string]string
+
+// connectorTypeAliases maps the connector types named differently by Xorg
+// drivers to the names of the kernel, which Wayland compositors use. The
+// types are compared without dashes. Some drivers don't tell the DVI
+// variants apart, so they are all matched as DVI.
+var connectorTypeAliases = map[string]string{
+	"HDMI":        "HDMIA",
+	"DISPLAYPORT": "DP",
+	"DVID":        "DVI",
+	"DVII":        "DVI",
+	"DVIA":        "DVI",
+}
+
+// ConnectorMapPath returns the path of the connector map in the config.
+func ConnectorMapPath() string {
+	return filepath.Join(configPath, "connectors.json")
+}
+
+// LoadConnectorMap reads the connector map from the config. It's empty if
+// the file doesn't exist.
+func LoadConnectorMap() (ConnectorMap, error) {
+	data, err := os.ReadFile(ConnectorMapPath())
+	if os.IsNotExist(err) {
+		return ConnectorMap{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading connector map: %w", err)
+	}
+
+	m := ConnectorMap{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing connector map %s: %w", ConnectorMapPath(), err)
+	}
+	return m, nil
+}
+
+// ParseConnectorMap parses a comma-separated list of mappings such as
+// "DP-3=DP-5,HDMI-1=HDMI-A-1".
+func ParseConnectorMap(s string) (ConnectorMap, error) {
+	m := ConnectorMap{}
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid connector mapping '%s', expected FROM=TO", pair)
+		}
+		m[from] = to
+	}
+	return m, nil
+}
+
+// NormalizeConnector returns the connector name in a form that is the same
+// for the naming schemes of Xorg and Wayland, e.g. HDMI1, HDMI-1 and
+// HDMI-A-1 are all HDMIA1.
+func NormalizeConnector(connector string) string {
+	name := strings.ToUpper(strings.ReplaceAll(connector, "-", ""))
+	typ := strings.TrimRight(name, "0123456789")
+	if alias, ok := connectorTypeAliases[typ]; ok {
+		return alias + name[len(typ):]
+	}
+	return name
+}
+
+// MatchConnector returns the connector if it's connected, or else the
+// connected output with the same normalized name. False is returned if there
+// is no such output or the name is ambiguous.
+func MatchConnector(connector string, res Resources) (string, bool) {
+	if _, ok := res.Monitors[connector]; ok {
+		return connector, true
+	}
+	normalized := NormalizeConnector(connector)
+	match := ""
+	for c := range res.Monitors {
+		if NormalizeConnector(c) == normalized {
+			if match != "" {
+				// Ambiguous
+				return "", false
+			}
+			match = c
+		}
+	}
+	return match, match != ""
+}
+
+// RemapConnectors returns the profile with its connectors renamed. Mapped
+// connectors are renamed unconditionally, aliases are resolved to the
+// connectors of their monitors and connectors that aren't connected are
+// renamed to a connected output with the same normalized name.
+func RemapConnectors(profile Profile, m ConnectorMap, aliases Aliases, res Resources) (Profile, error) {
+	rename := func(connector string) string {
+		if to, ok := m[connector]; ok {
+			return aliases.Resolve(to, res)
+		}
+		if to, ok := aliases.Connector(connector, res); ok {
+			return to
+		}
+		if match, ok := MatchConnector(connector, res); ok {
+			return match
+		}
+		return connector
+	}
+
+	// Renames are done at once so that connectors can be swapped
+	renamed := map[string]string{}
+	monitors := make([]LogicalMonitor, len(profile.Monitors))
+	for i, mon := range profile.Monitors {
+		mon = mon.Clone()
+		mon.Outputs = make(map[string]Mode, len(mon.Outputs))
+		for connector, mode := range profile.Monitors[i].Outputs {
+			to := rename(connector)
+			if from, ok := renamed[to]; ok {
+				return profile, fmt.Errorf("%w: outputs %s and %s are both mapped to %s",
+					ErrInvalidProfile, from, connector, to)
+			}
+			renamed[to] = connector
+			mon.Outputs[to] = mode
+		}
+		monitors[i] = mon
+	}
+	profile.Monitors = monitors
+
+	if profile.Disabled != nil {
+		// Outputs that are mapped to a configured connector aren't disabled
+		disabled := make([]string, 0, len(profile.Disabled))
+		for _, output := range profile.Disabled {
+			// Identities don't depend on the connector
+			if !strings.Contains(output, "/") {
+				output = rename(output)
+			}
+			if !profile.IsConfigured(output) {
+				disabled = append(disabled, output)
+			}
+		}
+		profile.Disabled = disabled
+	}
+
+	if profile.Brightness != nil {
+		brightness := make(map[string]int, len(profile.Brightness))
+		for connector, percent := range profile.Brightness {
+			brightness[rename(connector)] = percent
+		}
+		profile.Brightness = brightness
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,59 @@
+package common
+
+import "testing"
+
+func TestNormalizeConnector(t *testing.T) {
+	tests := []struct {
+		xorg, wayland string
+	}{
+		{"HDMI1", "HDMI-A-1"},
+		{"HDMI-1", "HDMI-A-1"},
+		{"DisplayPort-1", "DP-1"},
+		{"DP1", "DP-1"},
+		{"DVI-D-1", "DVI-D-1"},
+		{"DVI-I-1", "DVI-D-1"},
+		{"DVI1", "DVI-I-1"},
+		{"eDP1", "eDP-1"},
+	}
+	for _, tt := range tests {
+		if got, want := NormalizeConnector(tt.xorg), NormalizeConnector(tt.wayland); got != want {
+			t.Errorf("%s normalizes to %s, %s to %s", tt.xorg, got, tt.wayland, want)
+		}
+	}
+
+	if NormalizeConnector("DP-1") == NormalizeConnector("eDP-1") ||
+		NormalizeConnector("DVI-1") == NormalizeConnector("DVI-2") {
+		t.Error("different connectors normalize to the same name")
+	}
+}
+
+func TestMatchConnector(t *testing.T) {
+	res := Resources{Monitors: map[string]PhysicalMonitor{
+		"DP-1":     {},
+		"DVI-D-1":  {},
+		"HDMI-A-1": {},
+	}}
+
+	tests := []struct {
+		connector, want string
+		ok              bool
+	}{
+		{"DP-1", "DP-1", true},
+		{"DisplayPort-1", "DP-1", true},
+		{"DVI-I-1", "DVI-D-1", true},
+		{"HDMI1", "HDMI-A-1", true},
+		{"DP-2", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := MatchConnector(tt.connector, res)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.connector, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Two DVI outputs with the same number are ambiguous
+	res.Monitors["DVI-I-1"] = PhysicalMonitor{}
+	if got, ok := MatchConnector("DVI1", res); ok {
+		t.Errorf("DVI1: got %q with two DVI outputs", got)
+	}
+}
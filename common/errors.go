@@ -0,0 +1,51 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by desktop session backends and the profile store. Callers
+// should use errors.Is to check for them as they are usually wrapped with
+// additional context.
+var (
+	ErrUnsupportedSession = errors.New("unsupported desktop session")
+	ErrBackendUnavailable = errors.New("desktop session backend unavailable")
+	ErrNoMatchingMode     = errors.New("no matching mode")
+	ErrSerialMismatch     = errors.New("display configuration changed during apply")
+	ErrInvalidProfile     = errors.New("invalid profile")
+	ErrInvalidProfileName = errors.New("invalid profile name")
+	ErrProfileNotFound    = errors.New("profile not found")
+	ErrUnsupportedFeature = errors.New("not supported by the desktop session")
+)
+
+// ModeError is returned when a requested mode isn't supported by an output.
+type ModeError struct {
+	Connector string
+	Mode      Mode
+}
+
+func (e *ModeError) Error() string {
+	return fmt.Sprintf("no matching mode %s found for %s", e.Mode, e.Connector)
+}
+
+func (e *ModeError) Unwrap() error {
+	return ErrNoMatchingMode
+}
+
+// ProfileError is returned when a profile can't be used.
+type ProfileError struct {
+	Profile string
+	Err     error
+}
+
+func (e *ProfileError) Error() string {
+	if errors.Is(e.Err, ErrProfileNotFound) {
+		return fmt.Sprintf("profile '%s' does not exist", e.Profile)
+	}
+	return fmt.Sprintf("profile '%s': %s", e.Profile, e.Err)
+}
+
+func (e *ProfileError) Unwrap() error {
+	return e.Err
+}
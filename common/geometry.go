@@ -56,3 +56,159 @@ func Closest[F constraints.Float | constraints.Integer](scales []F, wanted F) F
 		return cmp.Compare(math.Abs(float64(wanted-a)), math.Abs(float64(wanted-b)))
 	})
 }
+
+// Size returns the size of the logical monitor in layout coordinates. The
+// mode of the first output is rotated according to the orientation and
+// divided by the scale.
+func (m LogicalMonitor) Size() Rect {
+	connectors := m.Connectors()
+	if len(connectors) == 0 {
+		return Rect{}
+	}
+
+	dims := m.Outputs[connectors[0]].Dimensions
+	if m.Orientation.Rotated() {
+		dims.X, dims.Y = dims.Y, dims.X
+	}
+	if m.Scale > 0 {
+		dims.X = int(math.Round(float64(dims.X) / m.Scale))
+		dims.Y = int(math.Round(float64(dims.Y) / m.Scale))
+	}
+	return dims
+}
+
+// SizeIn returns the size of the logical monitor in the coordinates of the
+// layout mode. Unlike in the logical layout mode, the scale doesn't affect
+// the size in the physical layout mode.
+func (m LogicalMonitor) SizeIn(mode LayoutMode) Rect {
+	if mode != LayoutModePhysical {
+		return m.Size()
+	}
+	m.Scale = 1
+	return m.Size()
+}
+
+// ConvertLayoutMode converts the offsets of the logical monitors between
+// layout modes. Monitors that are aligned or adjacent in the original layout
+// stay so and the other offsets are converted with the monitor's scale.
+func ConvertLayoutMode(monitors []LogicalMonitor, from, to LayoutMode) {
+	if from == to || from == "" || to == "" {
+		return
+	}
+
+	converted := make([]Rect, len(monitors))
+	convertAxis := func(get, other func(Rect) int, set func(*Rect, int)) {
+		order := make([]int, len(monitors))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(get(monitors[a].Offset), get(monitors[b].Offset))
+		})
+
+		for n, i := range order {
+			mon := monitors[i]
+			offset := get(mon.Offset)
+			otherStart := other(mon.Offset)
+			otherEnd := otherStart + other(mon.SizeIn(from))
+
+			// Align with a monitor which starts where this starts or attach
+			// to the end of one which ends where this starts. Only monitors
+			// next to this one on the other axis count, and ones sharing a
+			// side with it are preferred over ones only touching its edge.
+			best, bestRank := -1, 0
+			for _, j := range order[:n] {
+				start := get(monitors[j].Offset)
+				if start != offset && start+get(monitors[j].SizeIn(from)) != offset {
+					continue
+				}
+				jStart := other(monitors[j].Offset)
+				jEnd := jStart + other(monitors[j].SizeIn(from))
+				rank := 0
+				if otherStart < jEnd && jStart < otherEnd {
+					rank = 2
+				} else if otherStart == jEnd || jStart == otherEnd {
+					rank = 1
+				}
+				if rank > bestRank {
+					best, bestRank = j, rank
+				}
+			}
+			if best >= 0 {
+				if get(monitors[best].Offset) == offset {
+					set(&converted[i], get(converted[best]))
+				} else {
+					set(&converted[i], get(converted[best])+get(monitors[best].SizeIn(to)))
+				}
+				continue
+			}
+
+			scale := mon.Scale
+			if scale <= 0 {
+				scale = 1
+			}
+			if to == LayoutModeLogical {
+				scale = 1 / scale
+			}
+			set(&converted[i], int(math.Round(float64(offset)*scale)))
+		}
+	}
+
+	x := func(r Rect) int { return r.X }
+	y := func(r Rect) int { return r.Y }
+	convertAxis(x, y, func(r *Rect, v int) { r.X = v })
+	convertAxis(y, x, func(r *Rect, v int) { r.Y = v })
+
+	for i := range monitors {
+		monitors[i].Offset = converted[i]
+	}
+}
+
+// Bounds returns the top left and bottom right corners of the bounding box
+// of the logical monitors in the logical layout mode.
+func Bounds(monitors []LogicalMonitor) (Rect, Rect) {
+	return BoundsIn(monitors, LayoutModeLogical)
+}
+
+// BoundsIn returns the top left and bottom right corners of the bounding box
+// of the logical monitors in the coordinates of the layout mode.
+func BoundsIn(monitors []LogicalMonitor, mode LayoutMode) (Rect, Rect) {
+	if len(monitors) == 0 {
+		return Rect{}, Rect{}
+	}
+
+	topLeft := monitors[0].Offset
+	bottomRight := monitors[0].Offset.Add(monitors[0].SizeIn(mode))
+	for _, mon := range monitors[1:] {
+		end := mon.Offset.Add(mon.SizeIn(mode))
+		topLeft.X = min(topLeft.X, mon.Offset.X)
+		topLeft.Y = min(topLeft.Y, mon.Offset.Y)
+		bottomRight.X = max(bottomRight.X, end.X)
+		bottomRight.Y = max(bottomRight.Y, end.Y)
+	}
+	return topLeft, bottomRight
+}
+
+// NormalizeOffsets moves the logical monitors so that the top left corner of
+// the layout is at the origin.
+func NormalizeOffsets(monitors []LogicalMonitor) {
+	topLeft, _ := Bounds(monitors)
+	for i := range monitors {
+		monitors[i].Offset = monitors[i].Offset.Sub(topLeft)
+	}
+}
+
+// Overlaps returns true if the areas of the logical monitors intersect in
+// the logical layout mode.
+func Overlaps(a, b LogicalMonitor) bool {
+	return OverlapsIn(a, b, LayoutModeLogical)
+}
+
+// OverlapsIn returns true if the areas of the logical monitors intersect in
+// the coordinates of the layout mode.
+func OverlapsIn(a, b LogicalMonitor, mode LayoutMode) bool {
+	aEnd := a.Offset.Add(a.SizeIn(mode))
+	bEnd := b.Offset.Add(b.SizeIn(mode))
+	return a.Offset.X < bEnd.X && b.Offset.X < aEnd.X &&
+		a.Offset.Y < bEnd.Y && b.Offset.Y < aEnd.Y
+}
@@ -0,0 +1,32 @@
+package common
+
+import "testing"
+
+func TestConvertLayoutMode(t *testing.T) {
+	hd := Mode{Dimensions: Rect{X: 1920, Y: 1080}, Frequency: 60}
+	uhd := Mode{Dimensions: Rect{X: 3840, Y: 2160}, Frequency: 60}
+
+	// C is below A, which is twice as tall in physical pixels as B next to
+	// it, so C must stay attached to A
+	monitors := []LogicalMonitor{
+		{Outputs: map[string]Mode{"B": hd}, Scale: 1},
+		{Outputs: map[string]Mode{"A": uhd}, Scale: 2, Offset: Rect{X: 1920}},
+		{Outputs: map[string]Mode{"C": hd}, Scale: 1, Offset: Rect{X: 1920, Y: 1080}},
+	}
+
+	ConvertLayoutMode(monitors, LayoutModeLogical, LayoutModePhysical)
+	want := []Rect{{}, {X: 1920}, {X: 1920, Y: 2160}}
+	for i, mon := range monitors {
+		if mon.Offset != want[i] {
+			t.Errorf("monitor #%d: got offset %v, want %v", i, mon.Offset, want[i])
+		}
+	}
+
+	ConvertLayoutMode(monitors, LayoutModePhysical, LayoutModeLogical)
+	want = []Rect{{}, {X: 1920}, {X: 1920, Y: 1080}}
+	for i, mon := range monitors {
+		if mon.Offset != want[i] {
+			t.Errorf("monitor #%d: got logical offset %v, want %v", i, mon.Offset, want[i])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Hooks run around applying a profile
+const (
+	HookPreApply  = "pre-apply"
+	HookPostApply = "post-apply"
+)
+
+// DefaultHookTimeout is the time a hook may run before it's killed
+const DefaultHookTimeout = 10 * time.Second
+
+// ErrHookVetoed is returned when a pre-apply hook fails, which prevents the
+// profile from being applied.
+var ErrHookVetoed = errors.New("vetoed by hook")
+
+// HookContext describes the apply that the hooks are run for.
+type HookContext struct {
+	// Profile is the name of the profile, empty for unsaved layouts
+	Profile string
+	// Previous is the layout before applying
+	Previous State
+	// New is the layout being applied, or the resulting layout for
+	// post-apply hooks
+	New State
+}
+
+// HookDirs returns the directories of the hook in the order they are run:
+// the global directory first and then the profile's own directory.
+func HookDirs(hook, profile string) []string {
+	dirs := []string{filepath.Join(configPath, "hooks", hook+".d")}
+	if profile != "" {
+		dirs = append(dirs,
+			filepath.Join(configPath, "hooks", "profiles", profile, hook+".d"))
+	}
+	return dirs
+}
+
+// findHooks returns the executables in the hook directories sorted by name
+// within each directory.
+func findHooks(hook, profile string) ([]string, error) {
+	var hooks []string
+	for _, dir := range HookDirs(hook, profile) {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading hooks: %w", err)
+		}
+
+		var names []string
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			hooks = append(hooks, filepath.Join(dir, name))
+		}
+	}
+	return hooks, nil
+}
+
+// RunHooks runs the executables of the hook. The new state is written to the
+// standard input of each hook as JSON and the profile name and the previous
+// state are passed in the environment variables WAYLANDER_PROFILE and
+// WAYLANDER_PREVIOUS_STATE. The output of the hooks is written to the output.
+//
+// If a pre-apply hook fails, the remaining hooks are not run and the
+// returned error wraps ErrHookVetoed.
+func RunHooks(hook string, hctx HookContext, timeout time.Duration, output io.Writer) error {
+	hooks, err := findHooks(hook, hctx.Profile)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	newState, err := json.Marshal(hctx.New)
+	if err != nil {
+		return err
+	}
+	previousState, err := json.Marshal(hctx.Previous)
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(),
+		"WAYLANDER_HOOK="+hook,
+		"WAYLANDER_PROFILE="+hctx.Profile,
+		"WAYLANDER_PREVIOUS_STATE="+string(previousState),
+	)
+
+	var errs []error
+	for _, path := range hooks {
+		err := runHook(path, env, newState, timeout, output)
+		if err == nil {
+			continue
+		}
+		if hook == HookPreApply {
+			return fmt.Errorf("%w %s: %w", ErrHookVetoed, path, err)
+		}
+		errs = append(errs, fmt.Errorf("hook %s: %w", path, err))
+	}
+	return errors.Join(errs...)
+}
+
+func runHook(path string, env []string, input []byte, timeout time.Duration, output io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/maps"
+)
+
+// Direction is the direction in which the extend preset adds outputs.
+type Direction string
+
+const (
+	DirectionRight Direction = "right"
+	DirectionLeft  Direction = "left"
+	DirectionAbove Direction = "above"
+	DirectionBelow Direction = "below"
+)
+
+// ParseDirection parses a direction, returning false if it's invalid.
+func ParseDirection(s string) (Direction, bool) {
+	switch d := Direction(s); d {
+	case DirectionRight, DirectionLeft, DirectionAbove, DirectionBelow:
+		return d, true
+	}
+	return "", false
+}
+
+// MirrorPreset returns a layout in the layout mode that mirrors all connected
+// outputs using the largest resolution they have in common.
+func MirrorPreset(res Resources, mode LayoutMode) (Profile, error) {
+	connectors := presetOrder(res)
+	if len(connectors) == 0 {
+		return Profile{}, fmt.Errorf("no outputs connected")
+	}
+
+	// Find the largest dimensions supported by all outputs
+	var shared []Rect
+	for i, connector := range connectors {
+		var dims []Rect
+		for _, info := range res.Monitors[connector].Modes {
+			if i == 0 || slices.Contains(shared, info.Mode.Dimensions) {
+				dims = append(dims, info.Mode.Dimensions)
+			}
+		}
+		shared = dims
+	}
+	if len(shared) == 0 {
+		return Profile{}, fmt.Errorf("%w: the outputs have no resolution in common",
+			ErrNoMatchingMode)
+	}
+	largest := slices.MaxFunc(shared, func(a, b Rect) int {
+		return cmp.Compare(a.X*a.Y, b.X*b.Y)
+	})
+
+	mon := LogicalMonitor{
+		Outputs: map[string]Mode{},
+		Primary: true,
+	}
+	var infos []ModeInfo
+	for _, connector := range connectors {
+		info := fastestMode(res.Monitors[connector], largest)
+		mon.Outputs[connector] = info.Mode
+		infos = append(infos, info)
+	}
+	mon.Scale = commonScale(infos)
+
+	return Profile{
+		Monitors:   []LogicalMonitor{mon},
+		LayoutMode: mode,
+	}, nil
+}
+
+// ExtendPreset returns a layout where the outputs are placed next to each
+// other in their preferred modes. The built-in panel comes first and the
+// other outputs are added in the direction.
+func ExtendPreset(res Resources, mode LayoutMode, direction Direction) (Profile, error) {
+	return extend(res, mode, presetOrder(res), direction)
+}
+
+// OnlyPreset returns a layout with only the output enabled.
+func OnlyPreset(res Resources, mode LayoutMode, connector string) (Profile, error) {
+	if _, ok := res.Monitors[connector]; !ok {
+		return Profile{}, fmt.Errorf("output '%s' is not connected", connector)
+	}
+	return extend(res, mode, []string{connector}, DirectionRight)
+}
+
+// ExternalPreset returns a layout with the outputs other than the built-in
+// panel extended to the right.
+func ExternalPreset(res Resources, mode LayoutMode) (Profile, error) {
+	var external []string
+	for _, connector := range presetOrder(res) {
+		if !GetProperty[bool](res.Monitors[connector].Properties, PropertyBuiltin) {
+			external = append(external, connector)
+		}
+	}
+	if len(external) == 0 {
+		return Profile{}, fmt.Errorf("no external outputs connected")
+	}
+	return extend(res, mode, external, DirectionRight)
+}
+
+// extend places the outputs next to each other and disables the other
+// connected outputs. The offsets are in the coordinates of the layout mode.
+func extend(res Resources, mode LayoutMode, connectors []string, direction Direction) (Profile, error) {
+	if len(connectors) == 0 {
+		return Profile{}, fmt.Errorf("no outputs connected")
+	}
+
+	// Adding outputs to the left or above is the same as adding them in the
+	// reverse order to the right or below
+	order := slices.Clone(connectors)
+	if direction == DirectionLeft || direction == DirectionAbove {
+		slices.Reverse(order)
+	}
+
+	profile := Profile{LayoutMode: mode}
+	var offset Rect
+	for _, connector := range order {
+		info := preferredMode(res.Monitors[connector])
+		mon := LogicalMonitor{
+			Outputs: map[string]Mode{connector: info.Mode},
+			Scale:   commonScale([]ModeInfo{info}),
+			Offset:  offset,
+			Primary: connector == connectors[0],
+		}
+		size := mon.SizeIn(mode)
+		if direction == DirectionAbove || direction == DirectionBelow {
+			offset.Y += size.Y
+		} else {
+			offset.X += size.X
+		}
+		profile.Monitors = append(profile.Monitors, mon)
+	}
+
+	for _, connector := range presetOrder(res) {
+		if !profile.IsConfigured(connector) {
+			profile.Disabled = append(profile.Disabled, connector)
+		}
+	}
+	return profile, nil
+}
+
+// presetOrder returns the connectors with the built-in panel first and the
+// rest sorted by name.
+func presetOrder(res Resources) []string {
+	connectors := maps.Keys(res.Monitors)
+	slices.SortFunc(connectors, func(a, b string) int {
+		aBuiltin := GetProperty[bool](res.Monitors[a].Properties, PropertyBuiltin)
+		bBuiltin := GetProperty[bool](res.Monitors[b].Properties, PropertyBuiltin)
+		if aBuiltin != bBuiltin {
+			if aBuiltin {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a, b)
+	})
+	return connectors
+}
+
+// preferredMode returns the preferred mode of the monitor, or the largest
+// mode if none is preferred.
+func preferredMode(phys PhysicalMonitor) ModeInfo {
+	for _, info := range phys.Modes {
+		if info.Preferred {
+			return info
+		}
+	}
+	if info, ok := phys.FindMode(phys.PreferredMode); ok {
+		return info
+	}
+	if len(phys.Modes) == 0 {
+		return ModeInfo{}
+	}
+	largest := slices.MaxFunc(phys.Modes, func(a, b ModeInfo) int {
+		return cmp.Compare(a.Mode.Dimensions.X*a.Mode.Dimensions.Y,
+			b.Mode.Dimensions.X*b.Mode.Dimensions.Y)
+	})
+	return fastestMode(phys, largest.Mode.Dimensions)
+}
+
+// fastestMode returns the mode with the dimensions and the highest refresh
+// rate.
+func fastestMode(phys PhysicalMonitor, dims Rect) ModeInfo {
+	var best ModeInfo
+	for _, info := range phys.Modes {
+		if info.Mode.Dimensions.Eq(dims) && info.Mode.Frequency > best.Mode.Frequency {
+			best = info
+		}
+	}
+	return best
+}
+
+// commonScale returns the smallest preferred scale of the modes, snapped to
+// a scale all of them support.
+func commonScale(infos []ModeInfo) float64 {
+	scale := math.Inf(1)
+	for _, info := range infos {
+		if info.PreferredScale > 0 {
+			scale = min(scale, info.PreferredScale)
+		}
+	}
+	if math.IsInf(scale, 1) {
+		scale = 1
+	}
+
+	supported := infos[0].SupportedScales
+	for _, info := range infos[1:] {
+		supported = slices.DeleteFunc(slices.Clone(supported), func(s float64) bool {
+			return !slices.ContainsFunc(info.SupportedScales, func(t float64) bool {
+				return math.Abs(s-t) < Epsilon
+			})
+		})
+	}
+	if len(supported) == 0 {
+		return scale
+	}
+	return Closest(supported, scale)
+}
@@ -0,0 +1,50 @@
+package common
+
+import "testing"
+
+func TestExtendPresetLayoutMode(t *testing.T) {
+	panel := Mode{Dimensions: Rect{X: 1920, Y: 1080}, Frequency: 60}
+	tv := Mode{Dimensions: Rect{X: 3840, Y: 2160}, Frequency: 60}
+	res := Resources{Monitors: map[string]PhysicalMonitor{
+		"eDP-1": {
+			Modes: []ModeInfo{
+				{Mode: panel, PreferredScale: 1, SupportedScales: []float64{1, 2}, Preferred: true},
+			},
+			Properties: map[string]any{PropertyBuiltin: true},
+		},
+		"HDMI-1": {
+			Modes: []ModeInfo{
+				{Mode: tv, PreferredScale: 2, SupportedScales: []float64{1, 2}, Preferred: true},
+			},
+		},
+	}}
+
+	// The panel is added to the left of the TV, which is half as wide when
+	// it's scaled
+	tests := []struct {
+		mode LayoutMode
+		want int
+	}{
+		{LayoutModeLogical, 1920},
+		{LayoutModePhysical, 3840},
+	}
+	for _, tt := range tests {
+		profile, err := ExtendPreset(res, tt.mode, DirectionLeft)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.LayoutMode != tt.mode {
+			t.Errorf("%s: got layout mode %q", tt.mode, profile.LayoutMode)
+		}
+		if len(profile.Monitors) != 2 {
+			t.Fatalf("%s: got monitors %+v", tt.mode, profile.Monitors)
+		}
+		tvMon, panelMon := profile.Monitors[0], profile.Monitors[1]
+		if tvMon.Scale != 2 || tvMon.Offset != (Rect{}) {
+			t.Errorf("%s: got TV %+v", tt.mode, tvMon)
+		}
+		if panelMon.Offset != (Rect{X: tt.want}) || !panelMon.Primary {
+			t.Errorf("%s: got panel %+v, want offset %d", tt.mode, panelMon, tt.want)
+		}
+	}
+}
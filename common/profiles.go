@@ -0,0 +1,126 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProfilePath returns the path of the named profile's file.
+func ProfilePath(name string) string {
+	return filepath.Join(configPath, "profiles", fmt.Sprintf("%s.json", name))
+}
+
+// ValidProfileName returns false if the given name is not valid for a profile
+func ValidProfileName(profile string) bool {
+	return len(profile) > 0 && !strings.ContainsAny(profile, "/\\:;\n\t\r")
+}
+
+// ListProfiles returns the names of all saved profiles.
+func ListProfiles() ([]string, error) {
+	files, err := os.ReadDir(filepath.Join(configPath, "profiles"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading profiles: %w", err)
+	}
+
+	profiles := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" || len(f.Name()) <= 5 {
+			continue
+		}
+		profiles = append(profiles, f.Name()[:len(f.Name())-5])
+	}
+
+	return profiles, nil
+}
+
+// CheckProfile returns an error if the profile name is invalid or the
+// profile doesn't exist.
+func CheckProfile(name string) error {
+	if !ValidProfileName(name) {
+		return fmt.Errorf("%w '%s'", ErrInvalidProfileName, name)
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(profiles, name) {
+		return &ProfileError{Profile: name, Err: ErrProfileNotFound}
+	}
+	return nil
+}
+
+// ReadProfile returns the contents of the named profile's file.
+func ReadProfile(name string) ([]byte, error) {
+	if err := CheckProfile(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(ProfilePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile: %w", err)
+	}
+	return data, nil
+}
+
+// LoadProfile reads and parses the named profile.
+func LoadProfile(name string) (Profile, error) {
+	data, err := ReadProfile(name)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var profile Profile
+	err = json.Unmarshal(data, &profile)
+	if err != nil {
+		return Profile{}, &ProfileError{
+			Profile: name,
+			Err:     fmt.Errorf("%w: %w", ErrInvalidProfile, err),
+		}
+	}
+	return profile, nil
+}
+
+// SaveProfile writes the profile, replacing any existing profile with the
+// same name.
+func SaveProfile(name string, profile Profile) error {
+	if !ValidProfileName(name) {
+		return fmt.Errorf("%w '%s'", ErrInvalidProfileName, name)
+	}
+
+	EnsureConfigDir()
+	file, err := os.Create(ProfilePath(name))
+	if err != nil {
+		return fmt.Errorf("error creating profile: %w", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(profile)
+	if err != nil {
+		return fmt.Errorf("error saving profile: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteProfile deletes the named profile.
+func DeleteProfile(name string) error {
+	if err := CheckProfile(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(ProfilePath(name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting profile: %w", err)
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"math"
+	"slices"
 )
 
 const (
@@ -11,8 +14,24 @@ const (
 )
 
 const (
-	PropertyVRRSupported = "vrr_supported"
-	PropertyVRREnabled   = "vrr_enabled"
+	PropertyVRRSupported        = "vrr_supported"
+	PropertyVRREnabled          = "vrr_enabled"
+	PropertyColorModesSupported = "color_modes_supported"
+	PropertyColorMode           = "color_mode"
+
+	PropertyUnderscanningSupported = "underscanning_supported"
+	PropertyUnderscanning          = "underscanning"
+	PropertyPrivacyScreenSupported = "privacy_screen_supported"
+	PropertyPrivacyScreen          = "privacy_screen"
+
+	// PropertyBuiltin is true for the built-in panel of a laptop
+	PropertyBuiltin = "builtin"
+)
+
+// Color modes of the PropertyColorMode property
+const (
+	ColorModeDefault = "default"
+	ColorModeBT2100  = "bt2100"
 )
 
 type Mode struct {
@@ -57,19 +76,199 @@ type LogicalMonitor struct {
 	Properties  map[string]any  `json:"properties,omitempty"`
 }
 
+// Connectors returns the sorted connector names of the outputs.
+func (m LogicalMonitor) Connectors() []string {
+	connectors := make([]string, 0, len(m.Outputs))
+	for connector := range m.Outputs {
+		connectors = append(connectors, connector)
+	}
+	slices.Sort(connectors)
+	return connectors
+}
+
+// Clone returns a deep copy of the logical monitor.
+func (m LogicalMonitor) Clone() LogicalMonitor {
+	m.Outputs = maps.Clone(m.Outputs)
+	m.Properties = maps.Clone(m.Properties)
+	return m
+}
+
+// CloneMonitors returns a deep copy of the logical monitors.
+func CloneMonitors(monitors []LogicalMonitor) []LogicalMonitor {
+	cloned := make([]LogicalMonitor, len(monitors))
+	for i, mon := range monitors {
+		cloned[i] = mon.Clone()
+	}
+	return cloned
+}
+
 // PhysicalMonitor represents one connected physical monitor output.
 type PhysicalMonitor struct {
+	Vendor        string         `json:"vendor"`
+	Product       string         `json:"product"`
+	Serial        string         `json:"serial"`
+	PreferredMode Mode           `json:"preferred_mode"`
+	Modes         []ModeInfo     `json:"mode_info"`
+	Properties    map[string]any `json:"properties,omitempty"`
+}
+
+// physicalMonitorJSON is the JSON form of PhysicalMonitor. The modes are
+// listed as strings under "modes" as they always were, and with their
+// details under "mode_info".
+type physicalMonitorJSON struct {
 	Vendor        string         `json:"vendor"`
 	Product       string         `json:"product"`
 	Serial        string         `json:"serial"`
 	PreferredMode Mode           `json:"preferred_mode"`
 	Modes         []Mode         `json:"modes"`
+	ModeInfo      []ModeInfo     `json:"mode_info,omitempty"`
 	Properties    map[string]any `json:"properties,omitempty"`
 }
 
+func (p PhysicalMonitor) MarshalJSON() ([]byte, error) {
+	modes := make([]Mode, len(p.Modes))
+	for i, info := range p.Modes {
+		modes[i] = info.Mode
+	}
+	return json.Marshal(physicalMonitorJSON{
+		Vendor:        p.Vendor,
+		Product:       p.Product,
+		Serial:        p.Serial,
+		PreferredMode: p.PreferredMode,
+		Modes:         modes,
+		ModeInfo:      p.Modes,
+		Properties:    p.Properties,
+	})
+}
+
+// UnmarshalJSON also reads resources saved before the mode details were
+// added, which only list the modes.
+func (p *PhysicalMonitor) UnmarshalJSON(data []byte) error {
+	var v physicalMonitorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	infos := v.ModeInfo
+	if infos == nil && v.Modes != nil {
+		infos = make([]ModeInfo, len(v.Modes))
+		for i, mode := range v.Modes {
+			infos[i] = ModeInfo{
+				Mode:      mode,
+				Preferred: ModesEqual(mode, v.PreferredMode),
+			}
+		}
+	}
+	*p = PhysicalMonitor{
+		Vendor:        v.Vendor,
+		Product:       v.Product,
+		Serial:        v.Serial,
+		PreferredMode: v.PreferredMode,
+		Modes:         infos,
+		Properties:    v.Properties,
+	}
+	return nil
+}
+
+// Identity identifies the monitor regardless of the connector it's plugged
+// into, e.g. "GSM/LG TV/0x01010101".
+func (p PhysicalMonitor) Identity() string {
+	return p.Vendor + "/" + p.Product + "/" + p.Serial
+}
+
+// ModeInfo describes a mode supported by a physical monitor.
+type ModeInfo struct {
+	Mode            Mode      `json:"mode"`
+	PreferredScale  float64   `json:"preferred_scale"`
+	SupportedScales []float64 `json:"supported_scales"`
+	Interlaced      bool      `json:"interlaced,omitempty"`
+	// Current is true for the mode the monitor is using
+	Current   bool `json:"current,omitempty"`
+	Preferred bool `json:"preferred,omitempty"`
+}
+
+// FindMode returns the mode of the monitor with the same dimensions as the
+// wanted mode and the closest refresh rate.
+func (p PhysicalMonitor) FindMode(wanted Mode) (ModeInfo, bool) {
+	var best ModeInfo
+	found := false
+	for _, info := range p.Modes {
+		if !info.Mode.Dimensions.Eq(wanted.Dimensions) {
+			continue
+		}
+		if !found || math.Abs(info.Mode.Frequency-wanted.Frequency) <
+			math.Abs(best.Mode.Frequency-wanted.Frequency) {
+			best = info
+			found = true
+		}
+	}
+	return best, found
+}
+
 // Profile represents a complete monitor layout.
 type Profile struct {
 	Monitors []LogicalMonitor `json:"monitors"`
+	// LayoutMode is the layout mode the offsets are in. Profiles without a
+	// layout mode use the current mode of the session.
+	LayoutMode LayoutMode `json:"layout_mode,omitempty"`
+	// Disabled lists the outputs that must be off by connector or by
+	// identity (see PhysicalMonitor.Identity)
+	Disabled []string     `json:"disabled,omitempty"`
+	Audio    *AudioConfig `json:"audio,omitempty"`
+	// Brightness maps connectors to their backlight brightness in percent
+	Brightness map[string]int `json:"brightness,omitempty"`
+	NightLight *NightLight    `json:"night_light,omitempty"`
+}
+
+// NightLight represents the night light settings of the desktop.
+type NightLight struct {
+	Enabled bool `json:"enabled"`
+	// Temperature is the color temperature in kelvin, zero to keep the
+	// current temperature
+	Temperature uint32 `json:"temperature,omitempty"`
+}
+
+// IsDisabled returns true if the profile explicitly disables the output.
+func (p Profile) IsDisabled(connector string, phys PhysicalMonitor) bool {
+	return slices.Contains(p.Disabled, connector) ||
+		slices.Contains(p.Disabled, phys.Identity())
+}
+
+// IsConfigured returns true if a logical monitor of the profile uses the
+// output.
+func (p Profile) IsConfigured(connector string) bool {
+	for _, mon := range p.Monitors {
+		if _, ok := mon.Outputs[connector]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DisabledOutputs returns the sorted connectors of the connected outputs
+// that none of the logical monitors use.
+func DisabledOutputs(res Resources, monitors []LogicalMonitor) []string {
+	profile := Profile{Monitors: monitors}
+	var disabled []string
+	for connector := range res.Monitors {
+		if !profile.IsConfigured(connector) {
+			disabled = append(disabled, connector)
+		}
+	}
+	slices.Sort(disabled)
+	return disabled
+}
+
+// AudioConfig represents the audio outputs used with a layout.
+type AudioConfig struct {
+	// DefaultSink is the name of the default audio output
+	DefaultSink string `json:"default_sink,omitempty"`
+	// DefaultSinkDescription is used to find the default sink if there is
+	// no sink with the name
+	DefaultSinkDescription string `json:"default_sink_description,omitempty"`
+	// CardProfiles maps sound cards to their profiles, which choose e.g.
+	// the active HDMI output
+	CardProfiles map[string]string `json:"card_profiles,omitempty"`
 }
 
 // FindProperty checks if the properties contains a specific value with the
@@ -85,6 +284,24 @@ func FindProperty[T any](props map[string]any, key string) (T, bool) {
 	return t, ok
 }
 
+// GetStrings returns the string list property. Lists decoded from JSON are
+// converted from []any.
+func GetStrings(props map[string]any, key string) []string {
+	switch v := props[key].(type) {
+	case []string:
+		return v
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
 // GetProperty returns the value of the property, false/zero value if not found.
 func GetProperty[T any](props map[string]any, key string) T {
 	t, _ := FindProperty[T](props, key)
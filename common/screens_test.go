@@ -0,0 +1,52 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPhysicalMonitorJSON(t *testing.T) {
+	mode := Mode{Dimensions: Rect{X: 1920, Y: 1080}, Frequency: 60}
+	phys := PhysicalMonitor{
+		Vendor:        "BOE",
+		Product:       "Panel",
+		Serial:        "1",
+		PreferredMode: mode,
+		Modes: []ModeInfo{
+			{Mode: mode, PreferredScale: 1, SupportedScales: []float64{1, 2}, Current: true, Preferred: true},
+		},
+	}
+
+	data, err := json.Marshal(phys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// The modes stay a list of strings for existing consumers
+	if got := string(fields["modes"]); got != `["1920x1080 @60.000000"]` {
+		t.Errorf("got modes %s", got)
+	}
+
+	var decoded PhysicalMonitor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, phys) {
+		t.Errorf("got %+v, want %+v", decoded, phys)
+	}
+
+	// Resources without mode details are still read
+	old := `{"vendor": "BOE", "product": "Panel", "serial": "1",
+		"preferred_mode": "1920x1080 @60.000000",
+		"modes": ["1920x1080 @60.000000", "1280x720 @60.000000"]}`
+	if err := json.Unmarshal([]byte(old), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Modes) != 2 || !decoded.Modes[0].Preferred || decoded.Modes[1].Preferred {
+		t.Errorf("got modes %+v", decoded.Modes)
+	}
+}
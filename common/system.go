@@ -34,3 +34,8 @@ func EnsureConfigDir() {
 func GetConfigDir() string {
 	return configPath
 }
+
+// SetConfigDir overrides the config directory, e.g. in tests.
+func SetConfigDir(dir string) {
+	configPath = dir
+}
@@ -40,6 +40,11 @@ func (o Orientation) String() string {
 	return "Unknown"
 }
 
+// Rotated returns true if the orientation swaps the width and height.
+func (o Orientation) Rotated() bool {
+	return o%2 == 1
+}
+
 func (o Orientation) MarshalText() ([]byte, error) {
 	return []byte(o.String()), nil
 }
@@ -68,14 +73,37 @@ func (o *Orientation) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// LayoutMode tells how the offsets of logical monitors are interpreted.
+type LayoutMode string
+
+const (
+	// LayoutModeLogical offsets are in scaled pixels
+	LayoutModeLogical LayoutMode = "logical"
+	// LayoutModePhysical offsets are in the pixels of the outputs' modes
+	LayoutModePhysical LayoutMode = "physical"
+)
+
 type DesktopSession interface {
 	Resources() (Resources, error)
 	ScreenStates() ([]LogicalMonitor, error)
+	// LayoutMode returns the layout mode the session currently uses
+	LayoutMode() (LayoutMode, error)
+	Capabilities() (Capabilities, error)
 	Apply(profile Profile, verify, persistent bool) error
 	Close()
 	DebugInfo(output io.Writer) error
 }
 
+// DisplaySettings is implemented by desktop sessions that can change the
+// brightness and night light besides the layout.
+type DisplaySettings interface {
+	// Brightness returns the brightness of the outputs with a backlight
+	Brightness() (map[string]int, error)
+	SetBrightness(connector string, percent int) error
+	NightLight() (NightLight, error)
+	SetNightLight(nl NightLight) error
+}
+
 // State is the output of the state command
 type State struct {
 	Monitors []LogicalMonitor `json:"monitors"`
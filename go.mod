@@ -6,5 +6,8 @@ require (
 	github.com/alessio/shellescape v1.4.2
 	github.com/godbus/dbus/v5 v5.1.0
 	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b
+	golang.org/x/image v0.11.0
 	golang.org/x/sys v0.11.0
 )
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -1,6 +1,13 @@
 package mutter
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jclc/waylander/common"
+)
 
 // https://gitlab.gnome.org/GNOME/mutter/-/blob/main/data/dbus-interfaces/org.gnome.Mutter.DisplayConfig.xml
 
@@ -12,7 +19,7 @@ func (s *session) getState() error {
 	err := obj.Call("org.gnome.Mutter.DisplayConfig.GetCurrentState", 0).Store(
 		&s.serial, &s.st.Monitors, &s.st.LogicalMonitors, &s.st.Properties)
 	if err != nil {
-		return fmt.Errorf("failed to call Mutter d-bus API: %w", err)
+		return fmt.Errorf("failed to call Mutter d-bus API: %w", wrapCallError(err))
 	}
 
 	return nil
@@ -26,8 +33,32 @@ func (s *session) applyMonitorsConfig(method applyMethod, logicalMonitors []appl
 	err := obj.Call("org.gnome.Mutter.DisplayConfig.ApplyMonitorsConfig", 0,
 		s.serial, method, logicalMonitors, properties).Err
 	if err != nil {
-		return fmt.Errorf("failed to call Mutter d-bus API: %w", err)
+		return fmt.Errorf("failed to call Mutter d-bus API: %w", wrapCallError(err))
 	}
 
 	return nil
 }
+
+// wrapCallError maps the d-bus errors Mutter is known to return to the
+// corresponding common errors.
+func wrapCallError(err error) error {
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		return err
+	}
+
+	switch dbusErr.Name {
+	case "org.freedesktop.DBus.Error.ServiceUnknown",
+		"org.freedesktop.DBus.Error.NameHasNoOwner",
+		"org.freedesktop.DBus.Error.NoReply":
+		return fmt.Errorf("%w: %w", common.ErrBackendUnavailable, err)
+	case "org.freedesktop.DBus.Error.AccessDenied":
+		// Mutter rejects configurations based on an outdated serial
+		if strings.Contains(err.Error(), "stale") {
+			return fmt.Errorf("%w: %w", common.ErrSerialMismatch, err)
+		}
+	case "org.freedesktop.DBus.Error.InvalidArgs":
+		return fmt.Errorf("%w: %w", common.ErrInvalidProfile, err)
+	}
+	return err
+}
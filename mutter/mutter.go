@@ -16,14 +16,41 @@ const (
 	epsilon          = 0.005
 	preferredString  = "is-preferred"
 	currentString    = "is-current"
+	interlacedString = "is-interlaced"
 	vrrCapableString = "is-vrr-allowed"
 	vrrEnabledString = "allow_vrr"
+
+	colorModeString           = "color-mode"
+	supportedColorModesString = "supported-color-modes"
+
+	underscanningString          = "is-underscanning"
+	underscanningSupportedString = "supports-underscanning"
+	underscanningEnabledString   = "enable_underscanning"
+	privacyScreenString          = "privacy-screen-state"
+
+	layoutModeString                 = "layout-mode"
+	supportsChangingLayoutModeString = "supports-changing-layout-mode"
+	globalScaleRequiredString        = "global-scale-required"
+	supportsMirroringString          = "supports-mirroring"
 )
 
+// layoutModes maps Mutter's layout modes to the common layout modes
+var layoutModes = map[uint32]common.LayoutMode{
+	1: common.LayoutModeLogical,
+	2: common.LayoutModePhysical,
+}
+
+// colorModes maps Mutter's color modes to the common names
+var colorModes = map[uint32]string{
+	0: common.ColorModeDefault,
+	1: common.ColorModeBT2100,
+}
+
 func GetDesktopSession() (common.DesktopSession, error) {
 	conn, err := dbus.SessionBus()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to d-bus: %w", err)
+		return nil, fmt.Errorf("failed to connect to d-bus: %w: %w",
+			common.ErrBackendUnavailable, err)
 	}
 	s := &session{
 		conn: conn,
@@ -60,24 +87,51 @@ func (s *session) Resources() (common.Resources, error) {
 			Properties: map[string]any{},
 		}
 
-		mon.Modes = make([]common.Mode, 0, len(o.Modes))
+		mon.Modes = make([]common.ModeInfo, 0, len(o.Modes))
 		for _, mode := range o.Modes {
-			newMode := common.Mode{
-				Dimensions: common.Rect{
-					X: int(mode.Width),
-					Y: int(mode.Height),
+			newMode := common.ModeInfo{
+				Mode: common.Mode{
+					Dimensions: common.Rect{
+						X: int(mode.Width),
+						Y: int(mode.Height),
+					},
+					Frequency: mode.RefreshRate,
 				},
-				Frequency: mode.RefreshRate,
+				PreferredScale:  mode.PreferredScale,
+				SupportedScales: mode.SupportedScales,
+				Interlaced:      common.GetProperty[bool](mode.Properties, interlacedString),
+				Current:         common.GetProperty[bool](mode.Properties, currentString),
+				Preferred:       common.GetProperty[bool](mode.Properties, preferredString),
 			}
 			mon.Modes = append(mon.Modes, newMode)
 
-			if common.GetProperty[bool](mode.Properties, preferredString) {
-				mon.PreferredMode = newMode
+			if newMode.Preferred {
+				mon.PreferredMode = newMode.Mode
 			}
 		}
 
-		mon.Properties[common.PropertyVRRSupported] = common.GetProperty[bool](
-			o.Properties, vrrCapableString)
+		// The property is only reported for outputs that support VRR, its
+		// value is whether VRR is enabled
+		_, vrrSupported := common.FindProperty[bool](o.Properties, vrrCapableString)
+		mon.Properties[common.PropertyVRRSupported] = vrrSupported
+		mon.Properties[common.PropertyBuiltin] = common.GetProperty[bool](
+			o.Properties, builtinString)
+
+		// Older versions of Mutter don't support color modes
+		if ids, ok := common.FindProperty[[]uint32](o.Properties, supportedColorModesString); ok {
+			var supported []string
+			for _, id := range ids {
+				if name, ok := colorModes[id]; ok {
+					supported = append(supported, name)
+				}
+			}
+			mon.Properties[common.PropertyColorModesSupported] = supported
+		}
+
+		mon.Properties[common.PropertyUnderscanningSupported] = common.GetProperty[bool](
+			o.Properties, underscanningSupportedString)
+		_, hasPrivacyScreen := privacyScreenState(o)
+		mon.Properties[common.PropertyPrivacyScreenSupported] = hasPrivacyScreen
 
 		res.Monitors[o.Info.Connector] = mon
 	}
@@ -103,7 +157,13 @@ func (s *session) ScreenStates() ([]common.LogicalMonitor, error) {
 	var states []common.LogicalMonitor
 	for _, m := range s.st.LogicalMonitors {
 		inputs := make(map[string]common.Mode, len(m.Monitors))
+		var properties map[string]any
 		for _, in := range m.Monitors {
+			// Mirrored outputs share the properties of the logical monitor
+			if properties == nil {
+				properties = s.monitorProperties(in.Connector)
+			}
+
 			mode := currentModes[in.Connector]
 			inputs[in.Connector] = common.Mode{
 				Dimensions: common.Rect{
@@ -123,34 +183,143 @@ func (s *session) ScreenStates() ([]common.LogicalMonitor, error) {
 			Scale:       m.Scale,
 			Orientation: common.Orientation(m.Transform),
 			Primary:     m.Primary,
+			Properties:  properties,
 		})
 	}
 
 	return states, nil
 }
 
+// monitorProperties returns the common properties of the logical monitor
+// from the properties of its output, nil if there are none.
+func (s *session) monitorProperties(connector string) map[string]any {
+	mon, ok := s.findMonitor(connector)
+	if !ok {
+		return nil
+	}
+
+	props := map[string]any{}
+	// Mutter only reports whether VRR is allowed for outputs that support
+	// it, which is the setting applied with allow_vrr
+	if allowed, ok := common.FindProperty[bool](mon.Properties, vrrCapableString); ok {
+		props[common.PropertyVRREnabled] = allowed
+	}
+	if id, ok := common.FindProperty[uint32](mon.Properties, colorModeString); ok {
+		if name, ok := colorModes[id]; ok {
+			props[common.PropertyColorMode] = name
+		}
+	}
+	if common.GetProperty[bool](mon.Properties, underscanningSupportedString) {
+		props[common.PropertyUnderscanning] = common.GetProperty[bool](
+			mon.Properties, underscanningString)
+	}
+	if state, ok := privacyScreenState(mon); ok {
+		props[common.PropertyPrivacyScreen] = state.Enabled
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return props
+}
+
+func (s *session) findMonitor(connector string) (monitor, bool) {
+	for _, mon := range s.st.Monitors {
+		if mon.Info.Connector == connector {
+			return mon, true
+		}
+	}
+	return monitor{}, false
+}
+
+// colorModeID returns Mutter's ID of the color mode. The mode is ignored
+// and false is returned if Mutter doesn't support color modes.
+func (s *session) colorModeID(connector, name string) (uint32, bool, error) {
+	mon, _ := s.findMonitor(connector)
+	supported, ok := common.FindProperty[[]uint32](mon.Properties, supportedColorModesString)
+	if !ok {
+		return 0, false, nil
+	}
+	for _, id := range supported {
+		if colorModes[id] == name {
+			return id, true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("%w: output %s doesn't support color mode '%s'",
+		common.ErrInvalidProfile, connector, name)
+}
+
 func (s *session) Apply(profile common.Profile, verify, persistent bool) error {
 	err := s.getState()
 	if err != nil {
 		return err
 	}
 
+	// Offsets are only meaningful in the layout mode they were saved in, so
+	// either switch to the profile's layout mode or convert the offsets
+	var properties map[string]any
+	monitors := profile.Monitors
+	current := s.currentLayoutMode()
+	switch profile.LayoutMode {
+	case "", common.LayoutModeLogical, common.LayoutModePhysical:
+	default:
+		return fmt.Errorf("%w: invalid layout mode '%s'",
+			common.ErrInvalidProfile, profile.LayoutMode)
+	}
+	if profile.LayoutMode != "" && profile.LayoutMode != current {
+		if common.GetProperty[bool](s.st.Properties, supportsChangingLayoutModeString) {
+			for id, mode := range layoutModes {
+				if mode == profile.LayoutMode {
+					properties = map[string]any{layoutModeString: id}
+				}
+			}
+		} else {
+			monitors = common.CloneMonitors(profile.Monitors)
+			common.ConvertLayoutMode(monitors, profile.LayoutMode, current)
+		}
+	}
+
+	if err := s.capabilities().Check(profile, verify, persistent); err != nil {
+		return err
+	}
+
+	privacyScreen, changePrivacyScreen, err := s.privacyScreenSetting(profile)
+	if err != nil {
+		return err
+	}
+
+	// Mutter turns off the outputs that aren't configured, so disabled
+	// outputs only need to be checked for conflicts
+	for _, mon := range s.st.Monitors {
+		phys := common.PhysicalMonitor{
+			Vendor:  mon.Info.Vendor,
+			Product: mon.Info.Product,
+			Serial:  mon.Info.Serial,
+		}
+		if profile.IsDisabled(mon.Info.Connector, phys) &&
+			profile.IsConfigured(mon.Info.Connector) {
+			return fmt.Errorf("%w: output %s is both configured and disabled",
+				common.ErrInvalidProfile, mon.Info.Connector)
+		}
+	}
+
 	var outputMonitors []applyLogicalMonitor
-	for i, mon := range profile.Monitors {
+	for i, mon := range monitors {
 		connectors := maps.Keys(mon.Outputs)
 		slices.Sort(connectors)
 
 		if len(connectors) == 0 {
-			return fmt.Errorf("monitor #%d has no outputs", i)
+			return fmt.Errorf("%w: monitor #%d has no outputs",
+				common.ErrInvalidProfile, i)
 		} else if len(mon.Outputs) > 1 {
+
 			// When mirroring, all outputs must have the same dimensions
 			comp := mon.Outputs[connectors[0]]
 			for _, mode := range mon.Outputs {
 				if mode.Dimensions.X != comp.Dimensions.X ||
 					mode.Dimensions.Y != comp.Dimensions.Y {
 					return fmt.Errorf(
-						"cannot mirror outputs with different dimensions "+
-							"(%d,%d) and (%d,%d)",
+						"%w: cannot mirror outputs with different dimensions "+
+							"(%d,%d) and (%d,%d)", common.ErrInvalidProfile,
 						comp.Dimensions.X, comp.Dimensions.Y,
 						mode.Dimensions.X, mode.Dimensions.Y)
 				}
@@ -163,16 +332,38 @@ func (s *session) Apply(profile common.Profile, verify, persistent bool) error {
 		var scale float64
 		for _, connector := range connectors {
 			var id string
-			id, scale = s.findModeID(connector, mon.Outputs[connector], mon.Scale)
+			id, scale, err = s.findModeID(connector, mon.Outputs[connector], mon.Scale)
+			if err != nil {
+				return err
+			}
+
+			props := map[string]any{
+				vrrEnabledString: common.GetProperty[bool](
+					mon.Properties, common.PropertyVRREnabled,
+				),
+			}
+			if name, ok := common.FindProperty[string](mon.Properties, common.PropertyColorMode); ok {
+				colorMode, supported, err := s.colorModeID(connector, name)
+				if err != nil {
+					return err
+				} else if supported {
+					props[colorModeString] = colorMode
+				}
+			}
+			if enable, ok := common.FindProperty[bool](mon.Properties, common.PropertyUnderscanning); ok {
+				phys, _ := s.findMonitor(connector)
+				if common.GetProperty[bool](phys.Properties, underscanningSupportedString) {
+					props[underscanningEnabledString] = enable
+				} else if enable {
+					return fmt.Errorf("%w: output %s doesn't support underscanning",
+						common.ErrInvalidProfile, connector)
+				}
+			}
 
 			monitors = append(monitors, applyMonitor{
-				Connector: connector,
-				ModeID:    id,
-				Properties: map[string]any{
-					vrrEnabledString: common.GetProperty[bool](
-						mon.Properties, common.PropertyVRREnabled,
-					),
-				},
+				Connector:  connector,
+				ModeID:     id,
+				Properties: props,
 			})
 		}
 		outputMonitors = append(outputMonitors, applyLogicalMonitor{
@@ -191,20 +382,95 @@ func (s *session) Apply(profile common.Profile, verify, persistent bool) error {
 		method = applyPersistent
 	}
 
-	err = s.applyMonitorsConfig(method, outputMonitors, nil)
+	err = s.applyMonitorsConfig(method, outputMonitors, properties)
 	if err != nil {
 		return err
 	}
 	if persistent {
-		err = s.applyMonitorsConfig(applyPersistent, outputMonitors, nil)
+		err = s.applyMonitorsConfig(applyPersistent, outputMonitors, properties)
 		if err != nil {
 			return err
 		}
 	}
 
+	if changePrivacyScreen {
+		return setPrivacyScreen(privacyScreen)
+	}
 	return nil
 }
 
+func (s *session) Capabilities() (common.Capabilities, error) {
+	if err := s.getState(); err != nil {
+		return common.Capabilities{}, err
+	}
+	return s.capabilities(), nil
+}
+
+// capabilities returns the capabilities based on the last fetched state.
+// Features that are reported per output are supported if any of the
+// connected outputs supports them.
+func (s *session) capabilities() common.Capabilities {
+	caps := common.Capabilities{
+		Mirroring: common.GetPropertyDefault(s.st.Properties,
+			supportsMirroringString, true),
+		PerMonitorScale: !common.GetProperty[bool](s.st.Properties,
+			globalScaleRequiredString),
+		Verify:      true,
+		Persistence: true,
+		ChangeLayoutMode: common.GetProperty[bool](s.st.Properties,
+			supportsChangingLayoutModeString),
+		LayoutMode: s.currentLayoutMode(),
+		Brightness: s.hasBacklight(),
+		NightLight: s.hasNightLight(),
+	}
+
+	for _, mon := range s.st.Monitors {
+		for _, mode := range mon.Modes {
+			for _, scale := range mode.SupportedScales {
+				if scale != math.Trunc(scale) {
+					caps.FractionalScaling = true
+				}
+			}
+		}
+		if _, ok := common.FindProperty[bool](mon.Properties, vrrCapableString); ok {
+			caps.VRR = true
+		}
+		supported, ok := common.FindProperty[[]uint32](mon.Properties, supportedColorModesString)
+		if ok {
+			caps.ColorModes = true
+		}
+		for _, id := range supported {
+			if colorModes[id] == common.ColorModeBT2100 {
+				caps.HDR = true
+			}
+		}
+		if common.GetProperty[bool](mon.Properties, underscanningSupportedString) {
+			caps.Underscanning = true
+		}
+		if _, ok := privacyScreenState(mon); ok {
+			caps.PrivacyScreen = true
+		}
+	}
+	return caps
+}
+
+func (s *session) LayoutMode() (common.LayoutMode, error) {
+	if err := s.getState(); err != nil {
+		return "", err
+	}
+	return s.currentLayoutMode(), nil
+}
+
+// currentLayoutMode returns the layout mode of the last fetched state.
+// Mutter uses the logical layout mode if it doesn't report one.
+func (s *session) currentLayoutMode() common.LayoutMode {
+	id := common.GetProperty[uint32](s.st.Properties, layoutModeString)
+	if mode, ok := layoutModes[id]; ok {
+		return mode
+	}
+	return common.LayoutModeLogical
+}
+
 func (s *session) DebugInfo(output io.Writer) error {
 	err := s.getState()
 	if err != nil {
@@ -231,7 +497,7 @@ func (s *session) DebugInfo(output io.Writer) error {
 	return nil
 }
 
-func (s *session) findModeID(connector string, mode common.Mode, scale float64) (string, float64) {
+func (s *session) findModeID(connector string, mode common.Mode, scale float64) (string, float64, error) {
 	var best stMode
 	for _, monitor := range s.st.Monitors {
 		if monitor.Info.Connector != connector {
@@ -252,10 +518,10 @@ func (s *session) findModeID(connector string, mode common.Mode, scale float64)
 		}
 	}
 	if math.Abs(best.RefreshRate-mode.Frequency) > common.MaxAllowedFrequencyDeviation {
-		panic(fmt.Sprintf("no matching mode %s found for %s", mode, connector))
+		return "", 0, &common.ModeError{Connector: connector, Mode: mode}
 	}
 
 	newScale := common.Closest(best.SupportedScales, scale)
 
-	return best.ID, newScale
+	return best.ID, newScale, nil
 }
@@ -0,0 +1,321 @@
+package mutter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jclc/waylander/common"
+)
+
+// https://gitlab.gnome.org/GNOME/gnome-settings-daemon/-/blob/master/plugins/power/org.gnome.SettingsDaemon.Power.xml
+
+const (
+	builtinString = "is-builtin"
+
+	powerName            = "org.gnome.SettingsDaemon.Power"
+	powerPath            = "/org/gnome/SettingsDaemon/Power"
+	powerScreenInterface = "org.gnome.SettingsDaemon.Power.Screen"
+
+	colorSchema   = "org.gnome.settings-daemon.plugins.color"
+	privacySchema = "org.gnome.desktop.privacy"
+)
+
+// backlight is an entry of the Backlight property of Mutter, which newer
+// versions provide for all outputs with a backlight.
+type backlight struct {
+	Connector string
+	Min       int32
+	Max       int32
+	Value     int32
+}
+
+// percent returns the brightness in percent, rounded to the nearest percent
+// so that setting the returned value restores the same raw value.
+func (bl backlight) percent() int {
+	if bl.Max <= bl.Min {
+		return 100
+	}
+	return int(math.Round(float64(bl.Value-bl.Min) * 100 / float64(bl.Max-bl.Min)))
+}
+
+// raw returns the raw backlight value closest to the percentage.
+func (bl backlight) raw(percent int) int32 {
+	return bl.Min + int32(math.Round(float64(percent)*float64(bl.Max-bl.Min)/100))
+}
+
+var errNoBacklight = errors.New("output has no backlight")
+
+// backlights returns the backlights from Mutter. An error is returned if the
+// property is not supported.
+func (s *session) backlights() (uint32, []backlight, error) {
+	obj := s.conn.Object(
+		"org.gnome.Mutter.DisplayConfig",
+		"/org/gnome/Mutter/DisplayConfig")
+
+	v, err := obj.GetProperty("org.gnome.Mutter.DisplayConfig.Backlight")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var prop struct {
+		Serial     uint32
+		Backlights []map[string]dbus.Variant
+	}
+	if err := v.Store(&prop); err != nil {
+		return 0, nil, err
+	}
+
+	var bls []backlight
+	for _, props := range prop.Backlights {
+		var bl backlight
+		if err := props["connector"].Store(&bl.Connector); err != nil {
+			continue
+		}
+		_ = props["min"].Store(&bl.Min)
+		_ = props["max"].Store(&bl.Max)
+		_ = props["value"].Store(&bl.Value)
+		if bl.Max > bl.Min {
+			bls = append(bls, bl)
+		}
+	}
+	return prop.Serial, bls, nil
+}
+
+// builtinConnector returns the connector of the built-in panel, whose
+// backlight gnome-settings-daemon controls.
+func (s *session) builtinConnector() (string, error) {
+	if err := s.getState(); err != nil {
+		return "", err
+	}
+	for _, mon := range s.st.Monitors {
+		if common.GetProperty[bool](mon.Properties, builtinString) {
+			return mon.Info.Connector, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *session) screenBrightness() (int, error) {
+	v, err := s.conn.Object(powerName, powerPath).GetProperty(
+		powerScreenInterface + ".Brightness")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get brightness: %w", wrapCallError(err))
+	}
+	brightness, ok := v.Value().(int32)
+	if !ok || brightness < 0 {
+		return 0, errNoBacklight
+	}
+	return int(brightness), nil
+}
+
+// hasBacklight returns true if the brightness of any output can be
+// controlled.
+func (s *session) hasBacklight() bool {
+	if _, bls, err := s.backlights(); err == nil {
+		return len(bls) > 0
+	}
+	connector, err := s.builtinConnector()
+	if err != nil || connector == "" {
+		return false
+	}
+	_, err = s.screenBrightness()
+	return err == nil
+}
+
+func (s *session) Brightness() (map[string]int, error) {
+	brightness := map[string]int{}
+
+	if _, bls, err := s.backlights(); err == nil {
+		for _, bl := range bls {
+			brightness[bl.Connector] = bl.percent()
+		}
+		return brightness, nil
+	}
+
+	// Older versions of Mutter only allow controlling the built-in panel
+	// through gnome-settings-daemon
+	connector, err := s.builtinConnector()
+	if err != nil || connector == "" {
+		return brightness, err
+	}
+	value, err := s.screenBrightness()
+	if errors.Is(err, errNoBacklight) {
+		return brightness, nil
+	} else if err != nil {
+		return nil, err
+	}
+	brightness[connector] = value
+	return brightness, nil
+}
+
+func (s *session) SetBrightness(connector string, percent int) error {
+	percent = max(0, min(percent, 100))
+
+	if serial, bls, err := s.backlights(); err == nil {
+		for _, bl := range bls {
+			if bl.Connector != connector {
+				continue
+			}
+			value := bl.raw(percent)
+			err := s.conn.Object(
+				"org.gnome.Mutter.DisplayConfig",
+				"/org/gnome/Mutter/DisplayConfig",
+			).Call("org.gnome.Mutter.DisplayConfig.SetBacklight", 0,
+				serial, connector, value).Err
+			if err != nil {
+				return fmt.Errorf("failed to set brightness of %s: %w",
+					connector, wrapCallError(err))
+			}
+			return nil
+		}
+		return fmt.Errorf("%s: %w", connector, errNoBacklight)
+	}
+
+	builtin, err := s.builtinConnector()
+	if err != nil {
+		return err
+	}
+	if builtin != connector {
+		return fmt.Errorf("%s: %w", connector, errNoBacklight)
+	}
+	err = s.conn.Object(powerName, powerPath).SetProperty(
+		powerScreenInterface+".Brightness", dbus.MakeVariant(int32(percent)))
+	if err != nil {
+		return fmt.Errorf("failed to set brightness of %s: %w",
+			connector, wrapCallError(err))
+	}
+	return nil
+}
+
+// The night light settings are only stored in GSettings, so they're
+// accessed through the gsettings tool.
+
+// hasNightLight returns true if the night light settings are installed.
+func (s *session) hasNightLight() bool {
+	keys, err := gsettings("list-keys", colorSchema)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(strings.Fields(keys), "night-light-enabled")
+}
+
+func (s *session) NightLight() (common.NightLight, error) {
+	var nl common.NightLight
+
+	enabled, err := gsettings("get", colorSchema, "night-light-enabled")
+	if err != nil {
+		return nl, err
+	}
+	nl.Enabled = enabled == "true"
+
+	// Unsigned values are printed with their type, e.g. "uint32 4000"
+	temperature, err := gsettings("get", colorSchema, "night-light-temperature")
+	if err != nil {
+		return nl, err
+	}
+	t, err := strconv.ParseUint(strings.TrimPrefix(temperature, "uint32 "), 10, 32)
+	if err != nil {
+		return nl, fmt.Errorf("invalid night light temperature '%s'", temperature)
+	}
+	nl.Temperature = uint32(t)
+
+	return nl, nil
+}
+
+func (s *session) SetNightLight(nl common.NightLight) error {
+	_, err := gsettings("set", colorSchema, "night-light-enabled",
+		strconv.FormatBool(nl.Enabled))
+	if err != nil {
+		return err
+	}
+	if nl.Temperature != 0 {
+		_, err = gsettings("set", colorSchema, "night-light-temperature",
+			strconv.FormatUint(uint64(nl.Temperature), 10))
+	}
+	return err
+}
+
+func gsettings(args ...string) (string, error) {
+	out, err := exec.Command("gsettings", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("gsettings %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// privacyScreen is the privacy-screen-state property of a monitor.
+type privacyScreen struct {
+	Enabled bool
+	// Locked is true if the privacy screen is controlled by a hardware
+	// switch
+	Locked bool
+}
+
+// privacyScreenState returns the privacy screen state of the monitor, false
+// if the monitor doesn't have a privacy screen.
+func privacyScreenState(mon monitor) (privacyScreen, bool) {
+	var state privacyScreen
+	v, ok := common.FindProperty[[]any](mon.Properties, privacyScreenString)
+	if !ok || len(v) != 2 {
+		return state, false
+	}
+	state.Enabled, _ = v[0].(bool)
+	state.Locked, _ = v[1].(bool)
+	return state, true
+}
+
+// privacyScreenSetting checks the privacy screens of the profile's outputs
+// and returns the privacy-screen setting they need. Mutter doesn't accept
+// the privacy screen in the monitor configuration and instead follows the
+// setting, which applies to all outputs with a privacy screen. change is
+// false if the setting doesn't need to be changed.
+func (s *session) privacyScreenSetting(profile common.Profile) (enable, change bool, err error) {
+	var set bool
+	for _, mon := range profile.Monitors {
+		want, ok := common.FindProperty[bool](mon.Properties, common.PropertyPrivacyScreen)
+		if !ok {
+			continue
+		}
+		if set && want != enable {
+			return false, false, fmt.Errorf("%w: privacy screens can't be set separately "+
+				"for each output", common.ErrInvalidProfile)
+		}
+		enable, set = want, true
+
+		for _, connector := range mon.Connectors() {
+			phys, _ := s.findMonitor(connector)
+			state, ok := privacyScreenState(phys)
+			if !ok {
+				if enable {
+					return false, false, fmt.Errorf("%w: output %s doesn't have a privacy screen",
+						common.ErrInvalidProfile, connector)
+				}
+				continue
+			}
+			if state.Locked && state.Enabled != enable {
+				return false, false, fmt.Errorf("privacy screen of %s is controlled by a hardware switch",
+					connector)
+			}
+			if state.Enabled != enable {
+				change = true
+			}
+		}
+	}
+	return enable, change, nil
+}
+
+// setPrivacyScreen changes the privacy-screen setting.
+func setPrivacyScreen(enable bool) error {
+	_, err := gsettings("set", privacySchema, "privacy-screen", strconv.FormatBool(enable))
+	return err
+}
@@ -0,0 +1,115 @@
+package pulse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jclc/waylander/common"
+)
+
+// Sinks of HDMI outputs appear shortly after changing the card profile
+const sinkWaitTime = 2 * time.Second
+
+// Capture returns the current default sink and the profiles of cards with
+// HDMI outputs.
+func Capture() (common.AudioConfig, error) {
+	c, err := Connect()
+	if err != nil {
+		return common.AudioConfig{}, err
+	}
+	defer c.Close()
+
+	var cfg common.AudioConfig
+	cfg.DefaultSink, err = c.DefaultSink()
+	if err != nil {
+		return cfg, fmt.Errorf("error getting default sink: %w", err)
+	}
+
+	sinks, err := c.Sinks()
+	if err != nil {
+		return cfg, fmt.Errorf("error getting sinks: %w", err)
+	}
+	for _, sink := range sinks {
+		if sink.Name == cfg.DefaultSink {
+			cfg.DefaultSinkDescription = sink.Description
+		}
+	}
+
+	cards, err := c.Cards()
+	if err != nil {
+		return cfg, fmt.Errorf("error getting cards: %w", err)
+	}
+	for _, card := range cards {
+		if !strings.Contains(strings.ToLower(card.ActiveProfile), "hdmi") {
+			continue
+		}
+		if cfg.CardProfiles == nil {
+			cfg.CardProfiles = map[string]string{}
+		}
+		cfg.CardProfiles[card.Name] = card.ActiveProfile
+	}
+
+	return cfg, nil
+}
+
+// Apply sets the card profiles and then the default sink.
+func Apply(cfg common.AudioConfig) error {
+	c, err := Connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for card, profile := range cfg.CardProfiles {
+		if err := c.SetCardProfile(card, profile); err != nil {
+			return fmt.Errorf("error setting profile %s of card %s: %w",
+				profile, card, err)
+		}
+	}
+
+	if cfg.DefaultSink == "" && cfg.DefaultSinkDescription == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(sinkWaitTime)
+	for {
+		sink, err := findSink(c, cfg)
+		if err == nil {
+			return c.SetDefaultSink(sink)
+		}
+		if !errors.Is(err, errNoSink) || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+var errNoSink = errors.New("no matching sink")
+
+// findSink returns the name of the sink matching the name or, failing that,
+// the description.
+func findSink(c *Client, cfg common.AudioConfig) (string, error) {
+	sinks, err := c.Sinks()
+	if err != nil {
+		return "", fmt.Errorf("error getting sinks: %w", err)
+	}
+
+	for _, sink := range sinks {
+		if cfg.DefaultSink != "" && sink.Name == cfg.DefaultSink {
+			return sink.Name, nil
+		}
+	}
+	for _, sink := range sinks {
+		if cfg.DefaultSinkDescription != "" && sink.Description == cfg.DefaultSinkDescription {
+			return sink.Name, nil
+		}
+	}
+
+	name := cfg.DefaultSink
+	if name == "" {
+		name = cfg.DefaultSinkDescription
+	}
+	return "", fmt.Errorf("%w '%s'", errNoSink, name)
+}
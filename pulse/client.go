@@ -0,0 +1,422 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jclc/waylander/common"
+)
+
+// https://gitlab.freedesktop.org/pulseaudio/pulseaudio/-/blob/master/src/pulsecore/native-common.h
+
+const (
+	commandError          = 0
+	commandReply          = 2
+	commandAuth           = 8
+	commandSetClientName  = 9
+	commandGetServerInfo  = 20
+	commandGetSinkList    = 22
+	commandSetDefaultSink = 44
+	commandGetCardList    = 89
+	commandSetCardProfile = 90
+)
+
+const (
+	// protocolVersion is the protocol version waylander implements. Older
+	// servers lack the card port information.
+	protocolVersion    = 32
+	minProtocolVersion = 26
+	versionMask        = 0xffff
+
+	controlChannel = 0xffffffff
+	headerSize     = 20
+	cookieSize     = 256
+	maxPacketSize  = 16 * 1024 * 1024
+	requestTimeout = 5 * time.Second
+)
+
+// Error is an error returned by the server.
+type Error struct {
+	Code uint32
+}
+
+var errorNames = map[uint32]string{
+	1:  "access denied",
+	2:  "unknown command",
+	3:  "invalid argument",
+	4:  "entity exists",
+	5:  "no such entity",
+	6:  "connection refused",
+	7:  "protocol error",
+	8:  "timeout",
+	9:  "no authentication key",
+	10: "internal error",
+	11: "connection terminated",
+	12: "entity killed",
+	13: "invalid server",
+	19: "not supported",
+}
+
+func (e *Error) Error() string {
+	if name, ok := errorNames[e.Code]; ok {
+		return "pulseaudio: " + name
+	}
+	return fmt.Sprintf("pulseaudio: error %d", e.Code)
+}
+
+// Client is a minimal client of the PulseAudio native protocol, which is
+// also implemented by pipewire-pulse.
+type Client struct {
+	conn    net.Conn
+	tag     uint32
+	version uint32
+}
+
+// Sink is an audio output device.
+type Sink struct {
+	Index       uint32
+	Name        string
+	Description string
+}
+
+// Card is a sound card whose profile selects the active outputs.
+type Card struct {
+	Index         uint32
+	Name          string
+	Description   string
+	Profiles      []string
+	ActiveProfile string
+}
+
+// Connect connects to the server of the current session.
+func Connect() (*Client, error) {
+	conn, err := net.DialTimeout("unix", socketPath(), requestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to audio server: %w: %w",
+			common.ErrBackendUnavailable, err)
+	}
+
+	c := &Client{conn: conn}
+	if err := c.auth(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// socketPath returns the server's socket from $PULSE_SERVER or the default
+// location in the runtime directory.
+func socketPath() string {
+	if server := os.Getenv("PULSE_SERVER"); server != "" {
+		return strings.TrimPrefix(server, "unix:")
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(runtimeDir, "pulse", "native")
+}
+
+// readCookie returns the authentication cookie. pipewire-pulse doesn't use
+// cookies so a missing cookie is not an error.
+func readCookie() []byte {
+	paths := []string{os.Getenv("PULSE_COOKIE")}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "pulse", "cookie"))
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		paths = append(paths,
+			filepath.Join(home, ".config", "pulse", "cookie"),
+			filepath.Join(home, ".pulse-cookie"))
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if cookie, err := os.ReadFile(path); err == nil && len(cookie) >= cookieSize {
+			return cookie[:cookieSize]
+		}
+	}
+	return make([]byte, cookieSize)
+}
+
+func (c *Client) auth() error {
+	var w tagWriter
+	w.u32(protocolVersion)
+	w.arbitrary(readCookie())
+	r, err := c.request(commandAuth, &w)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to audio server: %w", err)
+	}
+
+	version, err := r.u32()
+	if err != nil {
+		return err
+	}
+	c.version = min(version&versionMask, protocolVersion)
+	if c.version < minProtocolVersion {
+		return fmt.Errorf("audio server protocol version %d is too old", c.version)
+	}
+
+	w = tagWriter{}
+	w.propList(map[string]string{"application.name": "waylander"})
+	_, err = c.request(commandSetClientName, &w)
+	return err
+}
+
+// request sends the command and returns the reply.
+func (c *Client) request(command uint32, args *tagWriter) (*tagReader, error) {
+	c.tag++
+	tag := c.tag
+
+	var w tagWriter
+	w.u32(command)
+	w.u32(tag)
+	if args != nil {
+		w.buf.Write(args.buf.Bytes())
+	}
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(w.buf.Len()))
+	binary.BigEndian.PutUint32(header[4:], controlChannel)
+
+	_ = c.conn.SetDeadline(time.Now().Add(requestTimeout))
+	if _, err := c.conn.Write(append(header, w.buf.Bytes()...)); err != nil {
+		return nil, err
+	}
+
+	for {
+		r, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+
+		cmd, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		replyTag, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		if replyTag != tag {
+			// Not a reply to this request
+			continue
+		}
+
+		switch cmd {
+		case commandReply:
+			return r, nil
+		case commandError:
+			code, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+			return nil, &Error{Code: code}
+		}
+		return nil, fmt.Errorf("unexpected reply command %d", cmd)
+	}
+}
+
+// readPacket reads one packet. Packets on other channels than the control
+// channel are skipped and returned as nil.
+func (c *Client) readPacket() (*tagReader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:])
+	if size > maxPacketSize {
+		return nil, errors.New("audio server packet too large")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint32(header[4:]) != controlChannel {
+		return nil, nil
+	}
+	return &tagReader{data: data}, nil
+}
+
+// DefaultSink returns the name of the default sink.
+func (c *Client) DefaultSink() (string, error) {
+	r, err := c.request(commandGetServerInfo, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// User name, host name, server version, server name and sample spec
+	if err := r.skip(5); err != nil {
+		return "", err
+	}
+	return r.string()
+}
+
+// SetDefaultSink sets the default sink by name.
+func (c *Client) SetDefaultSink(name string) error {
+	var w tagWriter
+	w.string(name)
+	_, err := c.request(commandSetDefaultSink, &w)
+	return err
+}
+
+// Sinks returns all sinks.
+func (c *Client) Sinks() ([]Sink, error) {
+	r, err := c.request(commandGetSinkList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []Sink
+	for len(r.data) > 0 {
+		var sink Sink
+		if sink.Index, err = r.u32(); err != nil {
+			return nil, err
+		}
+		if sink.Name, err = r.string(); err != nil {
+			return nil, err
+		}
+		if sink.Description, err = r.string(); err != nil {
+			return nil, err
+		}
+
+		// Sample spec, channel map, owner module, volume, mute, monitor
+		// source, monitor source name, latency, driver, flags, property
+		// list, configured latency, base volume, state, volume steps and
+		// card
+		if err := r.skip(16); err != nil {
+			return nil, err
+		}
+
+		nPorts, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		// Name, description, priority and availability of each port and
+		// the active port
+		if err := r.skip(int(nPorts)*4 + 1); err != nil {
+			return nil, err
+		}
+
+		nFormats, err := r.take(2)
+		if err != nil || nFormats[0] != tagU8 {
+			return nil, errShortTagstruct
+		}
+		if err := r.skip(int(nFormats[1])); err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// Cards returns all cards.
+func (c *Client) Cards() ([]Card, error) {
+	r, err := c.request(commandGetCardList, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []Card
+	for len(r.data) > 0 {
+		var card Card
+		if card.Index, err = r.u32(); err != nil {
+			return nil, err
+		}
+		if card.Name, err = r.string(); err != nil {
+			return nil, err
+		}
+		// Owner module and driver
+		if err := r.skip(2); err != nil {
+			return nil, err
+		}
+
+		nProfiles, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < nProfiles; i++ {
+			profile, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			card.Profiles = append(card.Profiles, profile)
+			// Description, sinks, sources, priority and, since version
+			// 29, availability
+			skip := 4
+			if c.version >= 29 {
+				skip = 5
+			}
+			if err := r.skip(skip); err != nil {
+				return nil, err
+			}
+		}
+		if card.ActiveProfile, err = r.string(); err != nil {
+			return nil, err
+		}
+
+		props, err := r.propList()
+		if err != nil {
+			return nil, err
+		}
+		card.Description = props["device.description"]
+
+		nPorts, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < nPorts; i++ {
+			// Name, description, priority, availability, direction and
+			// property list
+			if err := r.skip(6); err != nil {
+				return nil, err
+			}
+			nPortProfiles, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+			skip := int(nPortProfiles)
+			if c.version >= 27 {
+				// Latency offset
+				skip++
+			}
+			if err := r.skip(skip); err != nil {
+				return nil, err
+			}
+		}
+
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// SetCardProfile sets the active profile of the card by name.
+func (c *Client) SetCardProfile(card, profile string) error {
+	var w tagWriter
+	w.u32(invalidIndex)
+	w.string(card)
+	w.string(profile)
+	_, err := c.request(commandSetCardProfile, &w)
+	return err
+}
@@ -0,0 +1,444 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jclc/waylander/common"
+)
+
+// serverWriter writes the values of replies that the client only skips.
+type serverWriter struct {
+	tagWriter
+}
+
+func (w *serverWriter) u8(v uint8) {
+	w.buf.WriteByte(tagU8)
+	w.buf.WriteByte(v)
+}
+
+func (w *serverWriter) bool(v bool) {
+	if v {
+		w.buf.WriteByte(tagBoolTrue)
+	} else {
+		w.buf.WriteByte(tagBoolFalse)
+	}
+}
+
+func (w *serverWriter) sampleSpec() {
+	// s16le, stereo, 48 kHz
+	w.buf.Write([]byte{tagSampleSpec, 3, 2})
+	_ = binary.Write(&w.buf, binary.BigEndian, uint32(48000))
+}
+
+func (w *serverWriter) channelMap() {
+	w.buf.Write([]byte{tagChannelMap, 2, 1, 2})
+}
+
+func (w *serverWriter) cvolume() {
+	w.buf.Write([]byte{tagCVolume, 2})
+	_ = binary.Write(&w.buf, binary.BigEndian, [2]uint32{0x10000, 0x10000})
+}
+
+func (w *serverWriter) volume() {
+	w.buf.WriteByte(tagVolume)
+	_ = binary.Write(&w.buf, binary.BigEndian, uint32(0x10000))
+}
+
+func (w *serverWriter) usec(v uint64) {
+	w.buf.WriteByte(tagUsec)
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *serverWriter) s64(v int64) {
+	w.buf.WriteByte(tagS64)
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+// fakeServer implements the commands used by the client at a fixed protocol
+// version.
+type fakeServer struct {
+	version uint32
+
+	mu          sync.Mutex
+	clientName  string
+	defaultSink string
+	sinks       []Sink
+	cards       []Card
+	// profileSinks are the sinks that appear shortly after selecting the
+	// card profile
+	profileSinks map[string]Sink
+}
+
+const testSinkDelay = 300 * time.Millisecond
+
+func newFakeServer(version uint32) *fakeServer {
+	return &fakeServer{
+		version:     version,
+		defaultSink: "alsa_output.pci.analog-stereo",
+		sinks: []Sink{
+			{Index: 1, Name: "alsa_output.pci.analog-stereo", Description: "Built-in Audio Analog Stereo"},
+		},
+		cards: []Card{
+			{
+				Index:         1,
+				Name:          "alsa_card.pci",
+				Description:   "Built-in Audio",
+				Profiles:      []string{"output:analog-stereo", "output:hdmi-stereo", "off"},
+				ActiveProfile: "output:analog-stereo",
+			},
+			{
+				Index:         2,
+				Name:          "alsa_card.usb",
+				Description:   "USB Headset",
+				Profiles:      []string{"output:analog-stereo", "off"},
+				ActiveProfile: "output:analog-stereo",
+			},
+		},
+		profileSinks: map[string]Sink{
+			"output:hdmi-stereo": {Index: 2, Name: "alsa_output.pci.hdmi-stereo", Description: "LG TV"},
+		},
+	}
+}
+
+// start listens on a socket in a temporary directory and points the client
+// to it.
+func (s *fakeServer) start(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "native")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	t.Setenv("PULSE_SERVER", "unix:"+path)
+	t.Setenv("PULSE_COOKIE", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", dir)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	c := &Client{conn: conn}
+	for {
+		r, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		command, _ := r.u32()
+		tag, _ := r.u32()
+
+		var w serverWriter
+		code := s.handle(command, r, &w)
+
+		var packet tagWriter
+		if code != 0 {
+			packet.u32(commandError)
+			packet.u32(tag)
+			packet.u32(code)
+		} else {
+			packet.u32(commandReply)
+			packet.u32(tag)
+			packet.buf.Write(w.buf.Bytes())
+		}
+
+		header := make([]byte, headerSize)
+		binary.BigEndian.PutUint32(header[0:], uint32(packet.buf.Len()))
+		binary.BigEndian.PutUint32(header[4:], controlChannel)
+		if _, err := conn.Write(append(header, packet.buf.Bytes()...)); err != nil {
+			return
+		}
+	}
+}
+
+// handle writes the reply to the command or returns an error code.
+func (s *fakeServer) handle(command uint32, r *tagReader, w *serverWriter) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch command {
+	case commandAuth:
+		if v, err := r.u32(); err != nil || v < minProtocolVersion {
+			return 7
+		}
+		// Servers set flags in the upper bits
+		w.u32(s.version | 0x80000000)
+	case commandSetClientName:
+		props, err := r.propList()
+		if err != nil {
+			return 7
+		}
+		s.clientName = props["application.name"]
+		w.u32(1)
+	case commandGetServerInfo:
+		w.string("user")
+		w.string("host")
+		w.string("15.0")
+		w.string("pulseaudio")
+		w.sampleSpec()
+		w.string(s.defaultSink)
+		w.string("alsa_input.pci.analog-stereo")
+		w.u32(0x1234)
+		w.channelMap()
+	case commandGetSinkList:
+		for _, sink := range s.sinks {
+			s.writeSink(w, sink)
+		}
+	case commandGetCardList:
+		for _, card := range s.cards {
+			s.writeCard(w, card)
+		}
+	case commandSetCardProfile:
+		_, _ = r.u32()
+		name, _ := r.string()
+		profile, _ := r.string()
+		for i := range s.cards {
+			if s.cards[i].Name != name {
+				continue
+			}
+			for _, p := range s.cards[i].Profiles {
+				if p == profile {
+					s.cards[i].ActiveProfile = profile
+					s.addSinkLater(profile)
+					return 0
+				}
+			}
+		}
+		return 5
+	case commandSetDefaultSink:
+		name, _ := r.string()
+		for _, sink := range s.sinks {
+			if sink.Name == name {
+				s.defaultSink = name
+				return 0
+			}
+		}
+		return 5
+	default:
+		return 2
+	}
+	return 0
+}
+
+func (s *fakeServer) addSinkLater(profile string) {
+	sink, ok := s.profileSinks[profile]
+	if !ok {
+		return
+	}
+	time.AfterFunc(testSinkDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.sinks = append(s.sinks, sink)
+	})
+}
+
+func (s *fakeServer) writeSink(w *serverWriter, sink Sink) {
+	w.u32(sink.Index)
+	w.string(sink.Name)
+	w.string(sink.Description)
+	w.sampleSpec()
+	w.channelMap()
+	w.u32(invalidIndex)
+	w.cvolume()
+	w.bool(false)
+	w.u32(sink.Index)
+	w.string(sink.Name + ".monitor")
+	w.usec(0)
+	w.string("module-alsa-card.c")
+	w.u32(0)
+	w.propList(map[string]string{"device.description": sink.Description})
+	w.usec(0)
+	w.volume()
+	w.u32(0)
+	w.u32(65537)
+	w.u32(1)
+
+	w.u32(1)
+	w.string("hdmi-output-0")
+	w.string("HDMI / DisplayPort")
+	w.u32(5900)
+	w.u32(2)
+	w.string("hdmi-output-0")
+
+	w.u8(1)
+	w.buf.WriteByte(tagFormatInfo)
+	w.u8(1)
+	w.propList(nil)
+}
+
+func (s *fakeServer) writeCard(w *serverWriter, card Card) {
+	w.u32(card.Index)
+	w.string(card.Name)
+	w.u32(invalidIndex)
+	w.string("module-alsa-card.c")
+
+	w.u32(uint32(len(card.Profiles)))
+	for _, profile := range card.Profiles {
+		w.string(profile)
+		w.string(profile + " description")
+		w.u32(1)
+		w.u32(0)
+		w.u32(100)
+		if s.version >= 29 {
+			w.u32(1)
+		}
+	}
+	w.string(card.ActiveProfile)
+	w.propList(map[string]string{"device.description": card.Description})
+
+	w.u32(1)
+	w.string("analog-output")
+	w.string("Analog Output")
+	w.u32(100)
+	w.u32(0)
+	w.u8(1)
+	w.propList(nil)
+	w.u32(uint32(len(card.Profiles)))
+	for _, profile := range card.Profiles {
+		w.string(profile)
+	}
+	if s.version >= 27 {
+		w.s64(0)
+	}
+}
+
+var testVersions = []uint32{26, 29, 32}
+
+func TestCapture(t *testing.T) {
+	for _, version := range testVersions {
+		t.Run(versionName(version), func(t *testing.T) {
+			s := newFakeServer(version)
+			s.sinks = append(s.sinks, s.profileSinks["output:hdmi-stereo"])
+			s.cards[0].ActiveProfile = "output:hdmi-stereo"
+			s.defaultSink = "alsa_output.pci.hdmi-stereo"
+			s.start(t)
+
+			cfg, err := Capture()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := common.AudioConfig{
+				DefaultSink:            "alsa_output.pci.hdmi-stereo",
+				DefaultSinkDescription: "LG TV",
+				CardProfiles:           map[string]string{"alsa_card.pci": "output:hdmi-stereo"},
+			}
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("got %+v, want %+v", cfg, want)
+			}
+			if s.clientName != "waylander" {
+				t.Errorf("got client name %q", s.clientName)
+			}
+		})
+	}
+}
+
+func TestCards(t *testing.T) {
+	for _, version := range testVersions {
+		t.Run(versionName(version), func(t *testing.T) {
+			s := newFakeServer(version)
+			s.start(t)
+
+			c, err := Connect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if c.version != version {
+				t.Errorf("got version %d, want %d", c.version, version)
+			}
+
+			cards, err := c.Cards()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cards, s.cards) {
+				t.Errorf("got cards %+v, want %+v", cards, s.cards)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, version := range testVersions {
+		t.Run(versionName(version), func(t *testing.T) {
+			s := newFakeServer(version)
+			s.start(t)
+
+			// The HDMI sink appears only after the profile change and is
+			// found by its description
+			cfg := common.AudioConfig{
+				DefaultSink:            "alsa_output.hdmi-stereo-renamed",
+				DefaultSinkDescription: "LG TV",
+				CardProfiles:           map[string]string{"alsa_card.pci": "output:hdmi-stereo"},
+			}
+			start := time.Now()
+			if err := Apply(cfg); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < testSinkDelay {
+				t.Errorf("returned after %s, before the sink appeared", elapsed)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.defaultSink != "alsa_output.pci.hdmi-stereo" {
+				t.Errorf("got default sink %q", s.defaultSink)
+			}
+			if s.cards[0].ActiveProfile != "output:hdmi-stereo" {
+				t.Errorf("got card profile %q", s.cards[0].ActiveProfile)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	s := newFakeServer(protocolVersion)
+	s.start(t)
+
+	err := Apply(common.AudioConfig{CardProfiles: map[string]string{"alsa_card.pci": "output:missing"}})
+	var perr *Error
+	if !errors.As(err, &perr) || perr.Code != 5 {
+		t.Errorf("unknown profile: got error %v", err)
+	}
+
+	start := time.Now()
+	err = Apply(common.AudioConfig{DefaultSink: "alsa_output.missing"})
+	if !errors.Is(err, errNoSink) {
+		t.Errorf("unknown sink: got error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < sinkWaitTime {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
+
+func TestConnectOldServer(t *testing.T) {
+	s := newFakeServer(minProtocolVersion - 1)
+	s.start(t)
+
+	if _, err := Connect(); err == nil {
+		t.Error("connected to a server with an unsupported protocol version")
+	}
+}
+
+func versionName(version uint32) string {
+	return fmt.Sprintf("version %d", version)
+}
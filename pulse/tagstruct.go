@@ -0,0 +1,235 @@
+package pulse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// https://gitlab.freedesktop.org/pulseaudio/pulseaudio/-/blob/master/src/pulsecore/tagstruct.h
+
+const (
+	tagString     = 't'
+	tagStringNull = 'N'
+	tagU32        = 'L'
+	tagU8         = 'B'
+	tagU64        = 'R'
+	tagS64        = 'r'
+	tagSampleSpec = 'a'
+	tagArbitrary  = 'x'
+	tagBoolTrue   = '1'
+	tagBoolFalse  = '0'
+	tagTimeval    = 'T'
+	tagUsec       = 'U'
+	tagChannelMap = 'm'
+	tagCVolume    = 'v'
+	tagPropList   = 'P'
+	tagVolume     = 'V'
+	tagFormatInfo = 'f'
+	invalidIndex  = 0xffffffff
+)
+
+var errShortTagstruct = errors.New("tagstruct ended unexpectedly")
+
+// tagWriter builds a tagstruct.
+type tagWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *tagWriter) u32(v uint32) {
+	w.buf.WriteByte(tagU32)
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *tagWriter) string(s string) {
+	w.buf.WriteByte(tagString)
+	w.buf.WriteString(s)
+	w.buf.WriteByte(0)
+}
+
+func (w *tagWriter) arbitrary(data []byte) {
+	w.buf.WriteByte(tagArbitrary)
+	_ = binary.Write(&w.buf, binary.BigEndian, uint32(len(data)))
+	w.buf.Write(data)
+}
+
+func (w *tagWriter) propList(props map[string]string) {
+	w.buf.WriteByte(tagPropList)
+	for key, value := range props {
+		data := append([]byte(value), 0)
+		w.string(key)
+		w.u32(uint32(len(data)))
+		w.arbitrary(data)
+	}
+	w.buf.WriteByte(tagStringNull)
+}
+
+// tagReader reads a tagstruct.
+type tagReader struct {
+	data []byte
+}
+
+func (r *tagReader) take(n int) ([]byte, error) {
+	if n < 0 || len(r.data) < n {
+		return nil, errShortTagstruct
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *tagReader) tag(want byte) error {
+	b, err := r.take(1)
+	if err != nil {
+		return err
+	}
+	if b[0] != want {
+		return fmt.Errorf("unexpected tagstruct tag %q, expected %q", b[0], want)
+	}
+	return nil
+}
+
+func (r *tagReader) u32() (uint32, error) {
+	if err := r.tag(tagU32); err != nil {
+		return 0, err
+	}
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// string reads a string, returning an empty string for null strings.
+func (r *tagReader) string() (string, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return "", err
+	}
+	switch b[0] {
+	case tagStringNull:
+		return "", nil
+	case tagString:
+		end := bytes.IndexByte(r.data, 0)
+		if end < 0 {
+			return "", errShortTagstruct
+		}
+		s := string(r.data[:end])
+		r.data = r.data[end+1:]
+		return s, nil
+	}
+	return "", fmt.Errorf("unexpected tagstruct tag %q, expected string", b[0])
+}
+
+// propList reads a property list. Values are returned as strings without
+// their terminating null byte.
+func (r *tagReader) propList() (map[string]string, error) {
+	if err := r.tag(tagPropList); err != nil {
+		return nil, err
+	}
+
+	props := map[string]string{}
+	for {
+		key, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return props, nil
+		}
+		// The length is repeated in the arbitrary value
+		if _, err := r.u32(); err != nil {
+			return nil, err
+		}
+		if err := r.tag(tagArbitrary); err != nil {
+			return nil, err
+		}
+		lb, err := r.take(4)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.take(int(binary.BigEndian.Uint32(lb)))
+		if err != nil {
+			return nil, err
+		}
+		props[key] = string(bytes.TrimSuffix(value, []byte{0}))
+	}
+}
+
+// skip skips values of any type.
+func (r *tagReader) skip(n int) error {
+	for i := 0; i < n; i++ {
+		if err := r.skipValue(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *tagReader) skipValue() error {
+	b, err := r.take(1)
+	if err != nil {
+		return err
+	}
+
+	var size int
+	switch b[0] {
+	case tagStringNull, tagBoolTrue, tagBoolFalse:
+		return nil
+	case tagString:
+		end := bytes.IndexByte(r.data, 0)
+		if end < 0 {
+			return errShortTagstruct
+		}
+		size = end + 1
+	case tagU8:
+		size = 1
+	case tagU32, tagVolume:
+		size = 4
+	case tagSampleSpec:
+		size = 6
+	case tagU64, tagS64, tagTimeval, tagUsec:
+		size = 8
+	case tagArbitrary:
+		lb, err := r.take(4)
+		if err != nil {
+			return err
+		}
+		size = int(binary.BigEndian.Uint32(lb))
+	case tagChannelMap:
+		nb, err := r.take(1)
+		if err != nil {
+			return err
+		}
+		size = int(nb[0])
+	case tagCVolume:
+		nb, err := r.take(1)
+		if err != nil {
+			return err
+		}
+		size = int(nb[0]) * 4
+	case tagPropList:
+		for {
+			key, err := r.string()
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return nil
+			}
+			// Length followed by the value
+			if err := r.skip(2); err != nil {
+				return err
+			}
+		}
+	case tagFormatInfo:
+		// Encoding followed by a property list
+		return r.skip(2)
+	default:
+		return fmt.Errorf("unknown tagstruct tag %q", b[0])
+	}
+
+	_, err = r.take(size)
+	return err
+}
@@ -24,7 +24,7 @@ if [[ $? -ne 0 ]]; then
 fi
 
 for profile in "${delete[@]}"; do
-	msg=$(waylander delete "$profile")
+	msg=$(waylander delete "$profile" 2>&1)
 	if [[ $? -ne 0 ]]; then
 		zenity --notification --text="waylander error\n${msg}" --icon=error
 	fi
@@ -6,7 +6,7 @@ if [[ $? -ne 0 ]]; then
 	exit 1
 fi
 
-msg=$(waylander save "$name")
+msg=$(waylander save "$name" 2>&1)
 if [[ $? -ne 0 ]]; then
 	zenity --notification --text="waylander error\n${msg}" --icon=error
 fi
@@ -16,7 +16,7 @@ if [[ $? -ne 0 ]]; then
 	exit 1
 fi
 
-msg=$(waylander apply "$selected")
+msg=$(waylander apply "$selected" 2>&1)
 if [[ $? -ne 0 ]]; then
 	zenity --notification --text="waylander error\n${msg}" --icon=error
 fi